@@ -52,14 +52,20 @@ func main() {
 }
 
 func watch(ctx context.Context, filename string) {
-	ch, err := config.Watch(ctx, filename)
+	fileCh, err := config.Watch(ctx, filename)
 	if err != nil {
 		panic(err)
 	}
 
+	ch := config.Merge(ctx, fileCh, config.WatchSignals(ctx, syscall.SIGHUP))
+
 	for {
 		select {
-		case e := <-ch:
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+
 			if e != nil {
 				fmt.Printf("Error occured watching file: %v", e)
 				continue
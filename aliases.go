@@ -0,0 +1,227 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+// compileAliases sets the aliases of fp from the "aliases" struct-tag tag, a comma separated list of old names. Names
+// prefixed with "toml=", "env=" or "flag=" are only looked up in that source, e.g. "max_conns,env=APP_MAX_CONNS".
+// Other names are keys in the file, which are also looked up in the environment and the flags, by the names envAlias
+// and flagAlias derive from them, if fp has "env" and "flag" struct-tags.
+func (fp *fieldPlan) compileAliases(tag string) {
+	for _, alias := range strings.Split(tag, ",") {
+		alias = strings.TrimSpace(alias)
+		if alias == "" {
+			continue
+		}
+
+		source := ""
+		if i := strings.Index(alias, "="); i >= 0 {
+			source, alias = strings.TrimSpace(alias[:i]), strings.TrimSpace(alias[i+1:])
+		}
+
+		switch source {
+		case tomlTag:
+			fp.aliases = append(fp.aliases, alias)
+		case envTag:
+			fp.envAliases = append(fp.envAliases, alias)
+		case flagTag:
+			fp.flagAliases = append(fp.flagAliases, alias)
+		default:
+			fp.aliases = append(fp.aliases, alias)
+			if fp.envKey != "" {
+				fp.envAliases = append(fp.envAliases, envAlias(alias))
+			}
+			if fp.flagName != "" {
+				fp.flagAliases = append(fp.flagAliases, flagAlias(alias))
+			}
+		}
+	}
+}
+
+// hasAliases will check if fp has old names in any source.
+func (fp *fieldPlan) hasAliases() bool {
+	return len(fp.aliases) > 0 || len(fp.envAliases) > 0 || len(fp.flagAliases) > 0
+}
+
+// resolveAliases moves the values of the old names of the fields of typ in the file, from the "aliases" struct-tag, to
+// the keys of the fields, so that they are decoded as if the file used the new names. The new key wins if both are
+// set. table is the path of the table of typ in root, which is the root of the file, or an element of an array or map
+// of tables. Aliases are paths from root, so that fields can be moved between tables, e.g. `aliases:"max_conns"` on
+// pool.max_connections.
+//
+// fieldPath is the path of root in the file, which prefixes the names in warnings about deprecated ones.
+func resolveAliases(root *toml.Tree, table []string, typ reflect.Type, fieldPath string, src *sources) {
+	plan := planFor(typ, src.tags)
+	for i := range plan.fields {
+		fp := &plan.fields[i]
+		if len(fp.index) > 1 || fp.iface {
+			// Promoted from an unexported embedded struct, which go-toml doesn't decode, or unknown until decoded
+			continue
+		}
+
+		if fp.nested && fp.pathName == "" {
+			// Embedded structs are decoded from the same table
+			resolveAliases(root, table, derefType(fp.typ), fieldPath, src)
+			continue
+		}
+
+		keyPath := append(table[:len(table):len(table)], fieldKey(root, table, fp))
+		set := root.HasPath(keyPath)
+		if set && !fp.hasAliases() {
+			src.warnDeprecated(fp, SourceFile, prefixPath(fieldPath, strings.Join(keyPath, ".")))
+		}
+
+		for _, alias := range fp.aliases {
+			aliasPath := strings.Split(alias, ".")
+			if !root.HasPath(aliasPath) {
+				continue
+			}
+
+			src.warnDeprecated(fp, SourceFile, prefixPath(fieldPath, alias))
+			if !set {
+				root.SetPath(keyPath, root.GetPath(aliasPath))
+				root.SetPositionPath(keyPath, root.GetPositionPath(aliasPath))
+				set = true
+			}
+
+			deletePath(root, aliasPath)
+		}
+
+		switch {
+		case fp.structSlice:
+			elems, _ := root.GetPath(keyPath).([]*toml.Tree)
+			for i, elem := range elems {
+				elemPath := fmt.Sprintf("%s[%d]", strings.Join(keyPath, "."), i)
+				resolveAliases(elem, nil, derefType(fp.typ.Elem()), prefixPath(fieldPath, elemPath), src)
+			}
+		case fp.structMap:
+			elems, _ := root.GetPath(keyPath).(*toml.Tree)
+			if elems == nil {
+				continue
+			}
+
+			for _, key := range elems.Keys() {
+				if elem, ok := elems.GetPath([]string{key}).(*toml.Tree); ok {
+					elemPath := fmt.Sprintf("%s.%s", strings.Join(keyPath, "."), key)
+					resolveAliases(elem, nil, derefType(fp.typ.Elem()), prefixPath(fieldPath, elemPath), src)
+				}
+			}
+		case fp.nested:
+			resolveAliases(root, keyPath, derefType(fp.typ), fieldPath, src)
+		}
+	}
+}
+
+// fieldKey returns the key of the field fp in the table at path table of root, as spelled in the file if it's set.
+func fieldKey(root *toml.Tree, table []string, fp *fieldPlan) string {
+	if t, ok := root.GetPath(table).(*toml.Tree); ok {
+		if key, found := lookupKey(t, fp.tomlName, fp.name); found {
+			return key
+		}
+	}
+
+	if fp.tomlName != "" {
+		return fp.tomlName
+	}
+	return fp.name
+}
+
+// deletePath deletes path from tree, and the tables on the path that are left empty, so that strict decoding doesn't
+// fail on them.
+func deletePath(tree *toml.Tree, path []string) {
+	_ = tree.DeletePath(path)
+
+	for i := len(path) - 1; i > 0; i-- {
+		t, ok := tree.GetPath(path[:i]).(*toml.Tree)
+		if !ok || len(t.Keys()) > 0 {
+			return
+		}
+		_ = tree.DeletePath(path[:i])
+	}
+}
+
+// lookupField is lookupSources for the field fp, which is also looked up by its aliases in the flags and the
+// environment. It returns the name the value was found by, and warns if it is deprecated. Aliases in the file are
+// already resolved by resolveAliases.
+func lookupField(src *sources, fp *fieldPlan, flagName, envKey string, tree *toml.Tree, tomlKey string) (Source, string, string, bool) {
+	for _, source := range src.precedence {
+		if value, ok := lookupSource(src, source, flagName, envKey, tree, tomlKey); ok {
+			key := sourceKey(source, flagName, envKey, tomlKey)
+			if source != SourceFile && !fp.hasAliases() {
+				src.warnDeprecated(fp, source, key)
+			}
+			return source, key, value, true
+		}
+
+		aliases := fp.envAliases
+		if source == SourceFlag {
+			aliases = fp.flagAliases
+		} else if source != SourceEnv {
+			continue
+		}
+
+		for _, alias := range aliases {
+			var aliasFlag, aliasEnv string
+			if source == SourceFlag {
+				aliasFlag = alias
+			} else {
+				aliasEnv = alias
+			}
+			if src.indexed {
+				aliasEnv, aliasFlag = src.indexedNames(aliasEnv, aliasFlag)
+			}
+
+			if value, ok := lookupSource(src, source, aliasFlag, aliasEnv, nil, ""); ok {
+				key := sourceKey(source, aliasFlag, aliasEnv, "")
+				src.warnDeprecated(fp, source, key)
+				return source, key, value, true
+			}
+		}
+	}
+
+	return SourceNone, "", "", false
+}
+
+// envAlias returns the environment variable the alias of a field is looked up as, e.g. POOL_MAX_CONNS for
+// pool.max_conns.
+func envAlias(alias string) string {
+	return strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(alias))
+}
+
+// flagAlias returns the flag the alias of a field is looked up as, e.g. max-conns for max_conns.
+func flagAlias(alias string) string {
+	return strings.ReplaceAll(alias, "_", "-")
+}
+
+// sourceKey returns the name of the value in source, out of the names of a field.
+func sourceKey(source Source, flagName, envKey, tomlKey string) string {
+	switch source {
+	case SourceFlag:
+		return flagName
+	case SourceEnv:
+		return envKey
+	}
+	return tomlKey
+}
+
+// warnDeprecated warns that the field fp was set by the name key in source, if it is deprecated.
+func (src *sources) warnDeprecated(fp *fieldPlan, source Source, key string) {
+	if fp.deprecated == "" || src.logger == nil {
+		return
+	}
+
+	src.logger.Printf("config: %v %v is deprecated: %v", source, key, fp.deprecated)
+}
+
+// prefixPath returns path under prefix, e.g. "servers[0].host".
+func prefixPath(prefix, path string) string {
+	if prefix == "" {
+		return path
+	}
+	return fmt.Sprintf("%s.%s", prefix, path)
+}
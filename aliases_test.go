@@ -0,0 +1,248 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type testLogger struct {
+	msgs []string
+}
+
+func (l *testLogger) Printf(format string, v ...interface{}) {
+	l.msgs = append(l.msgs, fmt.Sprintf(format, v...))
+}
+
+type aliasTestConfig struct {
+	Name string `toml:"name" aliases:"app_name"`
+	Pool struct {
+		MaxConnections int `toml:"max_connections" env:"POOL_MAX_CONNECTIONS" flag:"pool-max-connections" aliases:"max_conns,maxconns,env=APP_MAX_CONNS,flag=maxconn" deprecated:"use pool.max_connections"`
+		Timeout        int `toml:"timeout" env:"POOL_TIMEOUT" aliases:"pool.wait,toml=pool.delay"`
+	} `toml:"pool"`
+	Debug   bool `toml:"debug" env:"DEBUG" deprecated:"use log.level"`
+	Servers []struct {
+		Addr string `toml:"addr" aliases:"address" deprecated:"use addr"`
+	} `toml:"servers"`
+}
+
+func TestLoad_Aliases(t *testing.T) {
+	testcases := []struct {
+		name     string
+		content  string
+		env      map[string]string
+		args     []string
+		expected func(cfg *aliasTestConfig)
+		warnings []string
+	}{
+		{
+			name:    "current names",
+			content: "name = \"app\"\n[pool]\nmax_connections = 10\ntimeout = 5\n",
+			expected: func(cfg *aliasTestConfig) {
+				cfg.Name = "app"
+				cfg.Pool.MaxConnections = 10
+				cfg.Pool.Timeout = 5
+			},
+		},
+		{
+			name:    "aliases in the file",
+			content: "app_name = \"app\"\nmax_conns = 10\n[pool]\nwait = 5\n",
+			expected: func(cfg *aliasTestConfig) {
+				cfg.Name = "app"
+				cfg.Pool.MaxConnections = 10
+				cfg.Pool.Timeout = 5
+			},
+			warnings: []string{"config: file max_conns is deprecated: use pool.max_connections"},
+		},
+		{
+			name:    "current name wins over alias",
+			content: "maxconns = 20\n[pool]\nmax_connections = 10\n",
+			expected: func(cfg *aliasTestConfig) {
+				cfg.Pool.MaxConnections = 10
+			},
+			warnings: []string{"config: file maxconns is deprecated: use pool.max_connections"},
+		},
+		{
+			name:    "alias in the environment",
+			content: "[pool]\nmax_connections = 10\n",
+			env:     map[string]string{"MAX_CONNS": "30"},
+			expected: func(cfg *aliasTestConfig) {
+				cfg.Pool.MaxConnections = 30
+			},
+			warnings: []string{"config: env MAX_CONNS is deprecated: use pool.max_connections"},
+		},
+		{
+			name:    "dotted alias in the environment",
+			content: "",
+			env:     map[string]string{"POOL_WAIT": "7"},
+			expected: func(cfg *aliasTestConfig) {
+				cfg.Pool.Timeout = 7
+			},
+		},
+		{
+			name:    "explicit alias in the environment",
+			content: "",
+			env:     map[string]string{"APP_MAX_CONNS": "60"},
+			expected: func(cfg *aliasTestConfig) {
+				cfg.Pool.MaxConnections = 60
+			},
+			warnings: []string{"config: env APP_MAX_CONNS is deprecated: use pool.max_connections"},
+		},
+		{
+			name:    "file only alias",
+			content: "[pool]\ndelay = 3\n",
+			env:     map[string]string{"POOL_DELAY": "9"},
+			expected: func(cfg *aliasTestConfig) {
+				cfg.Pool.Timeout = 3
+			},
+		},
+		{
+			name:    "current env name wins over alias",
+			content: "",
+			env:     map[string]string{"MAX_CONNS": "30", "POOL_MAX_CONNECTIONS": "40"},
+			expected: func(cfg *aliasTestConfig) {
+				cfg.Pool.MaxConnections = 40
+			},
+		},
+		{
+			name:    "alias flag",
+			content: "",
+			env:     map[string]string{"POOL_MAX_CONNECTIONS": "40"},
+			args:    []string{"-max-conns=50"},
+			expected: func(cfg *aliasTestConfig) {
+				cfg.Pool.MaxConnections = 50
+			},
+			warnings: []string{"config: flag max-conns is deprecated: use pool.max_connections"},
+		},
+		{
+			name:    "explicit alias flag",
+			content: "",
+			args:    []string{"-maxconn=70"},
+			expected: func(cfg *aliasTestConfig) {
+				cfg.Pool.MaxConnections = 70
+			},
+			warnings: []string{"config: flag maxconn is deprecated: use pool.max_connections"},
+		},
+		{
+			name:    "deprecated field",
+			content: "debug = true\n",
+			expected: func(cfg *aliasTestConfig) {
+				cfg.Debug = true
+			},
+			warnings: []string{"config: file debug is deprecated: use log.level"},
+		},
+		{
+			name:    "deprecated field in the environment",
+			content: "",
+			env:     map[string]string{"DEBUG": "true"},
+			expected: func(cfg *aliasTestConfig) {
+				cfg.Debug = true
+			},
+			warnings: []string{"config: env DEBUG is deprecated: use log.level"},
+		},
+		{
+			name:    "aliases in arrays of tables",
+			content: "[[servers]]\naddr = \"a\"\n\n[[servers]]\naddress = \"b\"\n",
+			expected: func(cfg *aliasTestConfig) {
+				cfg.Servers = []struct {
+					Addr string `toml:"addr" aliases:"address" deprecated:"use addr"`
+				}{{Addr: "a"}, {Addr: "b"}}
+			},
+			warnings: []string{"config: file servers[1].address is deprecated: use addr"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmp, _ := ioutil.TempFile("", "")
+			defer os.Remove(tmp.Name())
+
+			if _, err := tmp.WriteString(tc.content); err != nil {
+				t.Fatalf("write config file failed: %v", err)
+			}
+
+			fs := flag.NewFlagSet("tmp", flag.ContinueOnError)
+			_ = fs.Int("pool-max-connections", 0, "")
+			_ = fs.Int("max-conns", 0, "")
+			_ = fs.Int("maxconn", 0, "")
+			if err := fs.Parse(tc.args); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			logger := &testLogger{}
+			loader := New(WithFlagSet(fs), WithEnv(tc.env), WithLogger(logger), WithStrict())
+
+			var cfg aliasTestConfig
+			if err := loader.Load(tmp.Name(), &cfg); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			var expected aliasTestConfig
+			tc.expected(&expected)
+
+			if diff := cmp.Diff(expected, cfg); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+
+			if diff := cmp.Diff(tc.warnings, logger.msgs); diff != "" {
+				t.Errorf("warnings (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoad_AliasesWithoutLogger(t *testing.T) {
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString("debug = true\nmax_conns = 10\n"); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	fs := flag.NewFlagSet("tmp", flag.ContinueOnError)
+	_ = fs.Int("pool-max-connections", 0, "")
+	if err := fs.Parse(nil); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	var cfg aliasTestConfig
+	if err := New(WithFlagSet(fs), WithEnv(nil), WithLogger(nil)).Load(tmp.Name(), &cfg); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if !cfg.Debug || cfg.Pool.MaxConnections != 10 {
+		t.Errorf("got: %+v, expected debug and 10 max connections", cfg)
+	}
+}
+
+func TestLoad_AliasesStandardLogger(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString("debug = true\n"); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	fs := flag.NewFlagSet("tmp", flag.ContinueOnError)
+	_ = fs.Int("pool-max-connections", 0, "")
+
+	var cfg aliasTestConfig
+	if err := New(WithFlagSet(fs), WithEnv(nil)).Load(tmp.Name(), &cfg); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if expected := "config: file debug is deprecated: use log.level"; !strings.Contains(buf.String(), expected) {
+		t.Errorf("got: %q, expected: %q", buf.String(), expected)
+	}
+}
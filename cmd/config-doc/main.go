@@ -0,0 +1,133 @@
+// Command config-doc generates Markdown reference documentation for a config struct, using the same struct tags as
+// config.Load. It is meant to be used with go generate, in the package that defines the config type:
+//
+//	//go:generate go run github.com/peak/go-config/cmd/config-doc -type Config -o CONFIG.md
+//
+// The config type should be exported, and defined in a package other than main so it can be imported.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+var mainTemplate = template.Must(template.New("main").Parse(`// Code generated by config-doc. DO NOT EDIT.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/peak/go-config"
+
+	target {{ printf "%q" .ImportPath }}
+)
+
+func main() {
+{{- if .Title }}
+	fmt.Printf("# %s\n\n", {{ printf "%q" .Title }})
+{{- end }}
+	if err := config.GenerateMarkdown(os.Stdout, new(target.{{ .Type }})); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+`))
+
+type options struct {
+	ImportPath string
+	Type       string
+	Title      string
+}
+
+func main() {
+	typeName := flag.String("type", "", "Name of the config type (required)")
+	output := flag.String("o", "", "Output file, defaults to stdout")
+	title := flag.String("title", "", "Title of the generated document")
+	flag.Parse()
+
+	if err := run(*typeName, *output, *title); err != nil {
+		fmt.Fprintf(os.Stderr, "config-doc: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(typeName, output, title string) error {
+	if typeName == "" {
+		return errors.New("-type is required")
+	}
+
+	importPath, err := packageImportPath()
+	if err != nil {
+		return err
+	}
+
+	src, err := generateMain(options{ImportPath: importPath, Type: typeName, Title: title})
+	if err != nil {
+		return err
+	}
+
+	// The generator should live inside the module of the config type to resolve its imports.
+	dir, err := ioutil.TempDir(".", ".config-doc-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), src, 0644); err != nil {
+		return err
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.Command("go", "run", "./"+filepath.ToSlash(dir))
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+
+	if output == "" {
+		_, err = os.Stdout.Write(stdout.Bytes())
+		return err
+	}
+
+	return ioutil.WriteFile(output, stdout.Bytes(), 0644)
+}
+
+// packageImportPath returns the import path of the package in the current directory.
+func packageImportPath() (string, error) {
+	out, err := exec.Command("go", "list", "-f", "{{.ImportPath}} {{.Name}}", ".").Output()
+	if err != nil {
+		return "", fmt.Errorf("go list: %v", err)
+	}
+
+	fields := strings.Fields(string(out))
+	if len(fields) != 2 {
+		return "", fmt.Errorf("go list: unexpected output %q", out)
+	}
+
+	if fields[1] == "main" {
+		return "", errors.New("config type should not be defined in package main, which cannot be imported")
+	}
+
+	return fields[0], nil
+}
+
+// generateMain returns the source of the program that writes the documentation.
+func generateMain(opts options) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := mainTemplate.Execute(&buf, opts); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestGenerateMain(t *testing.T) {
+	src, err := generateMain(options{
+		ImportPath: "example.com/app/config",
+		Type:       "Config",
+		Title:      "Configuration",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if _, err := parser.ParseFile(token.NewFileSet(), "main.go", src, 0); err != nil {
+		t.Fatalf("generated source does not parse: %v\n%s", err, src)
+	}
+
+	for _, expected := range []string{
+		`target "example.com/app/config"`,
+		`new(target.Config)`,
+		`"Configuration"`,
+	} {
+		if !strings.Contains(string(src), expected) {
+			t.Errorf("expected %q in generated source:\n%s", expected, src)
+		}
+	}
+}
@@ -0,0 +1,403 @@
+// Command go-config validates, queries, formats and converts config files. validate loads files with the same code as
+// config.LoadTree, including the files they include, and merges or removes their profile tables as
+// config.WithProfile does. The other commands work on the files as they are written, so include directives and profile
+// tables are kept.
+//
+// Usage:
+//
+//	go-config validate [-schema config.schema.json] [-profile name] [-profile-table profile] file...
+//	go-config get key.path file
+//	go-config fmt [-w] file...
+//	go-config convert [-from toml|json|yaml] -to toml|json|yaml file
+//
+// Schemas are the ones generated by config.JSONSchema.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/peak/go-config"
+)
+
+const usage = `usage: go-config <command> [arguments]
+
+Commands:
+  validate [-schema config.schema.json] [-profile name] [-profile-table profile] file...
+        Check files for syntax errors, and unknown keys, types, required
+        keys, allowed values and bounds against a JSON Schema generated by
+        config.JSONSchema.
+        The overrides of the profile are merged, from the profile table and
+        the profile file, and the profile table is removed before checking.
+  get key.path file
+        Print the value of key.path.
+  fmt [-w] file...
+        Reformat files, printing the result or writing it back with -w.
+        Comments are not preserved, so -w refuses to write files that
+        have comments.
+  convert [-from toml|json|yaml] -to toml|json|yaml file
+        Convert file to another format. The input format defaults to the
+        file extension.
+`
+
+// profileEnv is the environment variable validate selects the profile of its -profile flag with.
+const profileEnv = "GO_CONFIG_PROFILE"
+
+// errFailed is returned by commands that already reported their errors.
+var errFailed = errors.New("failed")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	var err error
+	switch cmd, args := args[0], args[1:]; cmd {
+	case "validate":
+		err = runValidate(args, stdout, stderr)
+	case "get":
+		err = runGet(args, stdout)
+	case "fmt":
+		err = runFmt(args, stdout)
+	case "convert":
+		err = runConvert(args, stdout)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "go-config: unknown command %q\n\n%s", cmd, usage)
+		return 2
+	}
+
+	if err == errFailed {
+		return 1
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "go-config: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+func runValidate(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	schemaFile := fs.String("schema", "", "JSON Schema to validate against")
+	profile := fs.String("profile", "", "profile whose overrides are merged, e.g. prod")
+	profileTable := fs.String("profile-table", "profile", "table of the profile overrides")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		return errors.New("validate: no files given")
+	}
+
+	var s *schema
+	if *schemaFile != "" {
+		b, err := ioutil.ReadFile(*schemaFile)
+		if err != nil {
+			return err
+		}
+
+		s = new(schema)
+		if err := json.Unmarshal(b, s); err != nil {
+			return fmt.Errorf("invalid schema %v: %v", *schemaFile, err)
+		}
+	}
+
+	// Files are loaded with their profile as applications load them, so profile tables are not unknown keys
+	opts := []config.Option{
+		config.WithEnv(map[string]string{profileEnv: *profile}),
+		config.WithProfile(profileEnv, "", *profileTable),
+	}
+
+	failed := false
+	for _, file := range fs.Args() {
+		tree, err := config.LoadTree(file, opts...)
+		if err != nil {
+			fmt.Fprintln(stderr, withFile(file, err))
+			failed = true
+			continue
+		}
+
+		if s == nil {
+			continue
+		}
+
+		// Keys are reported in the included file they are loaded from
+		files, err := config.IncludedFiles(file, opts...)
+		if err != nil {
+			return err
+		}
+
+		for _, err := range s.validateTree(tree, nil) {
+			fmt.Fprintf(stderr, "%v:%v\n", err.keyFile(files, file), err)
+			failed = true
+		}
+	}
+
+	if failed {
+		return errFailed
+	}
+
+	fmt.Fprintln(stdout, "ok")
+	return nil
+}
+
+func runGet(args []string, stdout io.Writer) error {
+	if len(args) != 2 {
+		return errors.New("get: expected key path and file")
+	}
+
+	tree, err := toml.LoadFile(args[1])
+	if err != nil {
+		return withFile(args[1], err)
+	}
+
+	keys := strings.Split(args[0], ".")
+	if !tree.HasPath(keys) {
+		return fmt.Errorf("get: key %q not found", args[0])
+	}
+
+	switch v := tree.GetPath(keys).(type) {
+	case *toml.Tree:
+		b, err := v.Marshal()
+		if err != nil {
+			return err
+		}
+		_, err = stdout.Write(b)
+		return err
+	case string:
+		fmt.Fprintln(stdout, v)
+	default:
+		repr, err := toml.ValueStringRepresentation(v, "", "", toml.OrderAlphabetical, false)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, repr)
+	}
+
+	return nil
+}
+
+func runFmt(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	write := fs.Bool("w", false, "Write result to the file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		return errors.New("fmt: no files given")
+	}
+
+	for _, file := range fs.Args() {
+		src, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		tree, err := toml.LoadBytes(src)
+		if err != nil {
+			return withFile(file, err)
+		}
+
+		if *write && hasComments(src) {
+			return fmt.Errorf("fmt: %v has comments, which are not preserved; run without -w to print the result", file)
+		}
+
+		b, err := tree.Marshal()
+		if err != nil {
+			return fmt.Errorf("%v: %v", file, err)
+		}
+
+		if *write {
+			if err := ioutil.WriteFile(file, b, 0644); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, err := stdout.Write(b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// hasComments will check if the TOML document src has comments, skipping "#" in strings.
+func hasComments(src []byte) bool {
+	s := string(src)
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '#':
+			return true
+		case '"', '\'':
+			i = stringEnd(s, i)
+		}
+	}
+
+	return false
+}
+
+// stringEnd returns the index of the last quote of the TOML string that starts at i in s: a basic or literal string,
+// on a single line or on multiple lines. Unterminated strings end at the end of their line, or of s.
+func stringEnd(s string, i int) int {
+	quote := s[i : i+1]
+	if strings.HasPrefix(s[i:], strings.Repeat(quote, 3)) {
+		quote = strings.Repeat(quote, 3)
+	}
+
+	for j := i + len(quote); j < len(s); j++ {
+		switch {
+		case s[j] == '\\' && quote[0] == '"':
+			j++
+		case strings.HasPrefix(s[j:], quote):
+			return j + len(quote) - 1
+		case s[j] == '\n' && len(quote) == 1:
+			return j
+		}
+	}
+
+	return len(s)
+}
+
+func runConvert(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	from := fs.String("from", "", "Input format: toml, json or yaml (defaults to the file extension)")
+	to := fs.String("to", "", "Output format: toml, json or yaml")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return errors.New("convert: expected a single file")
+	}
+
+	file := fs.Arg(0)
+	if *from == "" {
+		*from = strings.TrimPrefix(filepath.Ext(file), ".")
+	}
+
+	m, err := decode(file, *from)
+	if err != nil {
+		return err
+	}
+
+	b, err := encode(m, *to)
+	if err != nil {
+		return err
+	}
+
+	_, err = stdout.Write(b)
+	return err
+}
+
+// decode reads file in the given format into a map.
+func decode(file, format string) (map[string]interface{}, error) {
+	if format == "toml" {
+		tree, err := toml.LoadFile(file)
+		if err != nil {
+			return nil, withFile(file, err)
+		}
+		return tree.ToMap(), nil
+	}
+
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]interface{}
+	switch format {
+	case "json":
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.UseNumber()
+		if err := d.Decode(&m); err != nil {
+			return nil, err
+		}
+		return normalizeJSON(m).(map[string]interface{}), nil
+	case "yaml", "yml":
+		if err := yaml.Unmarshal(b, &m); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+
+	return nil, fmt.Errorf("unknown input format %q", format)
+}
+
+// encode writes m in the given format.
+func encode(m map[string]interface{}, format string) ([]byte, error) {
+	switch format {
+	case "toml":
+		tree, err := toml.TreeFromMap(m)
+		if err != nil {
+			return nil, err
+		}
+		return tree.Marshal()
+	case "json":
+		b, err := json.MarshalIndent(m, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(b, '\n'), nil
+	case "yaml", "yml":
+		return yaml.Marshal(m)
+	}
+
+	return nil, fmt.Errorf("unknown output format %q", format)
+}
+
+// normalizeJSON converts the json.Number values in v into int64 or float64, so they can be encoded as TOML.
+func normalizeJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = normalizeJSON(e)
+		}
+		return v
+	case []interface{}:
+		for i, e := range v {
+			v[i] = normalizeJSON(e)
+		}
+		return v
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	}
+
+	return v
+}
+
+// withFile prefixes err with file, unless it is a config.FileError, which already has it.
+func withFile(file string, err error) error {
+	var fe *config.FileError
+	if errors.As(err, &fe) {
+		return err
+	}
+	return fmt.Errorf("%v: %v", file, err)
+}
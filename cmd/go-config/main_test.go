@@ -0,0 +1,453 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/peak/go-config"
+)
+
+type testConfig struct {
+	Name  string `toml:"name" required:"true"`
+	Level string `toml:"level" enum:"debug,info"`
+	Port  uint16 `toml:"port"`
+	Cache struct {
+		Size    *config.ByteSize `toml:"size" min:"64MiB" max:"4GiB"`
+		Usage   config.Percent   `toml:"usage" max:"100%"`
+		Timeout time.Duration    `toml:"timeout" max:"1m"`
+	} `toml:"cache"`
+	DB struct {
+		Host string `toml:"host" aliases:"db_host,database.hostname"`
+	} `toml:"database"`
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write file failed: %v", err)
+	}
+	return path
+}
+
+func writeSchema(t *testing.T, dir string) string {
+	t.Helper()
+
+	b, err := config.JSONSchema(&testConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	return writeFile(t, dir, "config.schema.json", string(b))
+}
+
+func TestValidate(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(dir)
+
+	schema := writeSchema(t, dir)
+
+	testcases := []struct {
+		name     string
+		content  string
+		expected []string
+	}{
+		{
+			name: "valid",
+			content: `
+name = "example"
+level = "info"
+
+[database]
+host = "localhost"
+`,
+		},
+		{
+			name: "aliases",
+			content: `
+name = "example"
+db_host = "localhost"
+
+[database]
+hostname = "localhost"
+`,
+		},
+		{
+			name: "bounds",
+			content: `
+name = "example"
+
+[cache]
+size = "1GiB"
+usage = 75
+timeout = "30s"
+`,
+		},
+		{
+			name: "out of bounds",
+			content: `
+name = "example"
+
+[cache]
+size = "8GiB"
+usage = "120%"
+timeout = 120000000000
+`,
+			expected: []string{
+				`5:1: key "cache.size" should be at most 4GiB, got 8GiB`,
+				`7:1: key "cache.timeout" should be at most 1m0s, got 120000000000`,
+				`6:1: key "cache.usage" should be at most 100%, got 120%`,
+			},
+		},
+		{
+			name: "below minimum and invalid units",
+			content: `
+name = "example"
+
+[cache]
+size = 1024
+usage = "most"
+`,
+			expected: []string{
+				`5:1: key "cache.size" should be at least 64MiB, got 1024`,
+				`6:1: key "cache.usage": invalid percentage "most"`,
+			},
+		},
+		{
+			name:     "syntax error",
+			content:  `name = "example`,
+			expected: []string{"config.toml:1:9: unclosed string", " 1 | name = \"example\n   |         ^"},
+		},
+		{
+			name: "unknown key",
+			content: `
+name = "example"
+
+[database]
+hots = "localhost"
+`,
+			expected: []string{`5:1: unknown key "database.hots"`},
+		},
+		{
+			name:     "missing required key",
+			content:  `level = "info"`,
+			expected: []string{`missing required key "name"`},
+		},
+		{
+			name: "wrong type and value",
+			content: `
+name = 42
+level = "trace"
+port = -1
+`,
+			expected: []string{
+				`2:1: key "name" should be of type string, got integer`,
+				`3:1: key "level" should be one of [debug info], got trace`,
+				`4:1: key "port" should be at least 0, got -1`,
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			file := writeFile(t, dir, "config.toml", tc.content)
+
+			var stdout, stderr bytes.Buffer
+			code := run([]string{"validate", "-schema", schema, file}, &stdout, &stderr)
+
+			if len(tc.expected) == 0 {
+				if code != 0 {
+					t.Fatalf("got exit code %v, expected 0: %v", code, stderr.String())
+				}
+				return
+			}
+
+			if code != 1 {
+				t.Fatalf("got exit code %v, expected 1", code)
+			}
+
+			for _, e := range tc.expected {
+				if !strings.Contains(stderr.String(), e) {
+					t.Errorf("expected %q in output, got:\n%v", e, stderr.String())
+				}
+			}
+		})
+	}
+}
+
+func TestValidate_Include(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(dir)
+
+	schema := writeSchema(t, dir)
+	writeFile(t, dir, "common.toml", "level = \"info\"\n\n[database]\nbogus = 1\n")
+	file := writeFile(t, dir, "app.toml", "include = [\"common.toml\"]\nname = 42\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"validate", "-schema", schema, file}, &stdout, &stderr); code != 1 {
+		t.Fatalf("got exit code %v, expected 1: %v", code, stderr.String())
+	}
+
+	expected := filepath.Join(dir, "common.toml") + `:4:1: unknown key "database.bogus"` + "\n" +
+		file + `:2:1: key "name" should be of type string, got integer` + "\n"
+	if got := stderr.String(); got != expected {
+		t.Errorf("got: %q, expected: %q", got, expected)
+	}
+}
+
+func TestValidate_Profile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(dir)
+
+	schema := writeSchema(t, dir)
+	file := writeFile(t, dir, "app.toml", "name = \"app\"\n\n[profile.prod]\nport = \"http\"\n\n[profile.dev]\nlevel = \"info\"\n")
+	writeFile(t, dir, "app.dev.toml", "[database]\nbogus = 1\n")
+
+	testcases := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{
+			name: "no profile",
+		},
+		{
+			name:     "profile table",
+			args:     []string{"-profile", "prod"},
+			expected: file + `:4:1: key "port" should be of type integer, got string` + "\n",
+		},
+		{
+			name:     "profile file",
+			args:     []string{"-profile", "dev"},
+			expected: filepath.Join(dir, "app.dev.toml") + `:2:1: unknown key "database.bogus"` + "\n",
+		},
+		{
+			name:     "other profile table",
+			args:     []string{"-profile-table", "env"},
+			expected: file + `:3:1: unknown key "profile"` + "\n",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			args := append(append([]string{"validate", "-schema", schema}, tc.args...), file)
+
+			var stdout, stderr bytes.Buffer
+			code := run(args, &stdout, &stderr)
+
+			if tc.expected == "" {
+				if code != 0 {
+					t.Fatalf("got exit code %v, expected 0: %v", code, stderr.String())
+				}
+				return
+			}
+
+			if code != 1 {
+				t.Fatalf("got exit code %v, expected 1", code)
+			}
+
+			if got := stderr.String(); got != tc.expected {
+				t.Errorf("got: %q, expected: %q", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(dir)
+
+	file := writeFile(t, dir, "config.toml", `
+name = "example"
+port = 8080
+
+[database]
+host = "localhost"
+`)
+
+	testcases := map[string]string{
+		"name":          "example\n",
+		"port":          "8080\n",
+		"database.host": "localhost\n",
+		"database":      "host = \"localhost\"\n",
+	}
+
+	for key, expected := range testcases {
+		var stdout, stderr bytes.Buffer
+		if code := run([]string{"get", key, file}, &stdout, &stderr); code != 0 {
+			t.Fatalf("got exit code %v, expected 0: %v", code, stderr.String())
+		}
+
+		if stdout.String() != expected {
+			t.Errorf("got: %q, expected: %q", stdout.String(), expected)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"get", "database.port", file}, &stdout, &stderr); code != 1 {
+		t.Fatalf("got exit code %v, expected 1", code)
+	}
+}
+
+func TestFmt(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(dir)
+
+	file := writeFile(t, dir, "config.toml", `name="example"
+[database]
+    host    =   "localhost"
+`)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"fmt", "-w", file}, &stdout, &stderr); code != 0 {
+		t.Fatalf("got exit code %v, expected 0: %v", code, stderr.String())
+	}
+
+	b, _ := ioutil.ReadFile(file)
+	expected := "name = \"example\"\n\n[database]\n  host = \"localhost\"\n"
+	if string(b) != expected {
+		t.Errorf("got: %q, expected: %q", b, expected)
+	}
+}
+
+func TestFmt_Comments(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(dir)
+
+	content := "# Service settings\nname=\"example\"\n"
+	file := writeFile(t, dir, "config.toml", content)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"fmt", "-w", file}, &stdout, &stderr); code != 1 {
+		t.Fatalf("got exit code %v, expected 1", code)
+	}
+
+	if !strings.Contains(stderr.String(), "has comments") {
+		t.Errorf("got: %q, expected an error about comments", stderr.String())
+	}
+
+	if b, _ := ioutil.ReadFile(file); string(b) != content {
+		t.Errorf("got: %q, expected the file unchanged", b)
+	}
+
+	if code := run([]string{"fmt", file}, &stdout, &stderr); code != 0 {
+		t.Fatalf("got exit code %v, expected 0: %v", code, stderr.String())
+	}
+
+	if got, expected := stdout.String(), "name = \"example\"\n"; got != expected {
+		t.Errorf("got: %q, expected: %q", got, expected)
+	}
+}
+
+func TestHasComments(t *testing.T) {
+	testcases := []struct {
+		content  string
+		expected bool
+	}{
+		{"name = \"app\"\n", false},
+		{"name = \"app\" # the name\n", true},
+		{"# comment\n", true},
+		{"color = \"#fff\"\n", false},
+		{"color = '#fff'\n", false},
+		{"quote = \"say \\\"#1\\\"\"\n", false},
+		{"\"#key\" = 1\n", false},
+		{"text = \"\"\"\n# not a comment \\\"\"\"\n\"\"\"\n", false},
+		{"text = '''\n# not a comment\n'''\n# comment\n", true},
+		{"empty = \"\"\n# comment\n", true},
+	}
+
+	for _, tc := range testcases {
+		if got := hasComments([]byte(tc.content)); got != tc.expected {
+			t.Errorf("%q: got: %v, expected: %v", tc.content, got, tc.expected)
+		}
+	}
+}
+
+func TestFmt_KeepsDirectives(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(dir)
+
+	writeFile(t, dir, "common.toml", "level = \"info\"\n")
+	file := writeFile(t, dir, "app.toml", `include = ["common.toml"]
+name = "app"
+
+[profile.prod]
+name = "prod"
+`)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"fmt", "-w", file}, &stdout, &stderr); code != 0 {
+		t.Fatalf("got exit code %v, expected 0: %v", code, stderr.String())
+	}
+
+	b, _ := ioutil.ReadFile(file)
+	expected := "include = [\"common.toml\"]\nname = \"app\"\n\n[profile]\n\n  [profile.prod]\n    name = \"prod\"\n"
+	if string(b) != expected {
+		t.Errorf("got: %q, expected: %q", b, expected)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"get", "profile.prod.name", file}, &stdout, &stderr); code != 0 {
+		t.Fatalf("got exit code %v, expected 0: %v", code, stderr.String())
+	}
+
+	if got, expected := stdout.String(), "prod\n"; got != expected {
+		t.Errorf("got: %q, expected: %q", got, expected)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(dir)
+
+	file := writeFile(t, dir, "config.toml", `
+name = "example"
+port = 8080
+
+[database]
+host = "localhost"
+`)
+
+	var jsonOut, yamlOut, tomlOut, stderr bytes.Buffer
+
+	if code := run([]string{"convert", "-to", "json", file}, &jsonOut, &stderr); code != 0 {
+		t.Fatalf("got exit code %v, expected 0: %v", code, stderr.String())
+	}
+
+	jsonFile := writeFile(t, dir, "config.json", jsonOut.String())
+	if code := run([]string{"convert", "-to", "yaml", jsonFile}, &yamlOut, &stderr); code != 0 {
+		t.Fatalf("got exit code %v, expected 0: %v", code, stderr.String())
+	}
+
+	expectedYAML := "database:\n    host: localhost\nname: example\nport: 8080\n"
+	if yamlOut.String() != expectedYAML {
+		t.Errorf("got: %q, expected: %q", yamlOut.String(), expectedYAML)
+	}
+
+	yamlFile := writeFile(t, dir, "config.yaml", yamlOut.String())
+	if code := run([]string{"convert", "-to", "toml", yamlFile}, &tomlOut, &stderr); code != 0 {
+		t.Fatalf("got exit code %v, expected 0: %v", code, stderr.String())
+	}
+
+	var cfg testConfig
+	tomlFile := writeFile(t, dir, "roundtrip.toml", tomlOut.String())
+	if err := config.Load(tomlFile, &cfg); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if cfg.Name != "example" || cfg.Port != 8080 || cfg.DB.Host != "localhost" {
+		t.Errorf("unexpected config after round trip: %#v", cfg)
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"frobnicate"}, &stdout, &stderr); code != 2 {
+		t.Fatalf("got exit code %v, expected 2", code)
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml"
+
+	"github.com/peak/go-config"
+)
+
+// schema is the subset of JSON Schema generated by config.JSONSchema.
+type schema struct {
+	Type                 schemaType         `json:"type"`
+	Properties           map[string]*schema `json:"properties"`
+	AdditionalProperties *additional        `json:"additionalProperties"`
+	Required             []string           `json:"required"`
+	Items                *schema            `json:"items"`
+	Enum                 []interface{}      `json:"enum"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	Format               string             `json:"format"`
+}
+
+// schemaType is the "type" keyword, which is either a single type or a list of types.
+type schemaType []string
+
+func (t *schemaType) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*t = schemaType{single}
+		return nil
+	}
+
+	return json.Unmarshal(b, (*[]string)(t))
+}
+
+// additional is the "additionalProperties" keyword, which is either a boolean or a schema.
+type additional struct {
+	allowed bool
+	schema  *schema
+}
+
+func (a *additional) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &a.allowed); err == nil {
+		return nil
+	}
+
+	a.allowed = true
+	return json.Unmarshal(b, &a.schema)
+}
+
+// validationError is a schema violation at a position in the file. path is the path of the key, or of the table for
+// missing keys, to find the file the key was loaded from.
+type validationError struct {
+	pos  toml.Position
+	path []string
+	msg  string
+}
+
+func (e validationError) Error() string {
+	return fmt.Sprintf("%v:%v: %v", e.pos.Line, e.pos.Col, e.msg)
+}
+
+// validateTree checks the keys of tree against s.
+func (s *schema) validateTree(tree *toml.Tree, path []string) []validationError {
+	var errs []validationError
+
+	keys := tree.Keys()
+	sort.Strings(keys)
+	for _, key := range keys {
+		keyPath := append(append([]string{}, path...), key)
+		pos := tree.GetPosition(key)
+
+		prop, ok := s.Properties[key]
+		if !ok && s.AdditionalProperties != nil {
+			if !s.AdditionalProperties.allowed {
+				errs = append(errs, validationError{pos, keyPath, fmt.Sprintf("unknown key %q", strings.Join(keyPath, "."))})
+				continue
+			}
+			prop = s.AdditionalProperties.schema
+		}
+
+		if prop != nil {
+			errs = append(errs, prop.validateValue(tree.Get(key), keyPath, pos)...)
+		}
+	}
+
+	for _, key := range s.Required {
+		if !tree.Has(key) {
+			keyPath := append(append([]string{}, path...), key)
+			errs = append(errs, validationError{tree.Position(), path, fmt.Sprintf("missing required key %q", strings.Join(keyPath, "."))})
+		}
+	}
+
+	return errs
+}
+
+// validateValue checks val against s.
+func (s *schema) validateValue(val interface{}, path []string, pos toml.Position) []validationError {
+	key := strings.Join(path, ".")
+
+	typ := valueType(val)
+	if !s.allows(typ) {
+		return []validationError{{pos, path, fmt.Sprintf("key %q should be of type %v, got %v", key, strings.Join(s.Type, " or "), typ)}}
+	}
+
+	var errs []validationError
+	switch v := val.(type) {
+	case *toml.Tree:
+		errs = append(errs, s.validateTree(v, path)...)
+	case []*toml.Tree:
+		for i, t := range v {
+			if s.Items != nil {
+				errs = append(errs, s.Items.validateTree(t, append(path[:len(path):len(path)], fmt.Sprint(i)))...)
+			}
+		}
+	case []interface{}:
+		for i, e := range v {
+			if s.Items != nil {
+				errs = append(errs, s.Items.validateValue(e, append(path[:len(path):len(path)], fmt.Sprint(i)), pos)...)
+			}
+		}
+	}
+
+	if len(s.Enum) > 0 && !s.inEnum(val) {
+		errs = append(errs, validationError{pos, path, fmt.Sprintf("key %q should be one of %v, got %v", key, s.Enum, val)})
+	}
+
+	f, ok, err := s.number(val)
+	if err != nil {
+		return append(errs, validationError{pos, path, fmt.Sprintf("key %q: %v", key, err)})
+	}
+
+	if ok && s.Minimum != nil && f < *s.Minimum {
+		errs = append(errs, validationError{pos, path, fmt.Sprintf("key %q should be at least %v, got %v", key, s.formatNumber(*s.Minimum), val)})
+	}
+
+	if ok && s.Maximum != nil && f > *s.Maximum {
+		errs = append(errs, validationError{pos, path, fmt.Sprintf("key %q should be at most %v, got %v", key, s.formatNumber(*s.Maximum), val)})
+	}
+
+	return errs
+}
+
+// number returns val as the number it is compared to the bounds of s as. Strings of the formats of config.JSONSchema
+// are parsed like the loader does, e.g. "64MiB" into bytes. It returns false if val is not a number.
+func (s *schema) number(val interface{}) (float64, bool, error) {
+	if f, ok := toFloat(val); ok {
+		return f, true, nil
+	}
+
+	str, ok := val.(string)
+	if !ok {
+		return 0, false, nil
+	}
+
+	switch s.Format {
+	case "go-duration":
+		d, err := time.ParseDuration(str)
+		return float64(d), err == nil, err
+	case "byte-size":
+		b, err := config.ParseByteSize(str)
+		return float64(b), err == nil, err
+	case "percent":
+		p, err := config.ParsePercent(str)
+		return float64(p), err == nil, err
+	}
+
+	return 0, false, nil
+}
+
+// formatNumber returns the bound f as it is written in the format of s, e.g. "64MiB" for a byte size.
+func (s *schema) formatNumber(f float64) string {
+	switch s.Format {
+	case "go-duration":
+		return time.Duration(f).String()
+	case "byte-size":
+		return config.ByteSize(f).String()
+	case "percent":
+		return config.Percent(f).String()
+	}
+
+	return fmt.Sprint(f)
+}
+
+// keyFile returns the file of files that the key of e was loaded from: the last file with the key at the position of
+// e, as files are merged in order. It returns def if no file has it.
+func (e validationError) keyFile(files []string, def string) string {
+	for i := len(files) - 1; i >= 0; i-- {
+		tree, err := toml.LoadFile(files[i])
+		if err != nil {
+			continue
+		}
+
+		if pos, ok := positionAt(tree, e.path); ok && pos == e.pos {
+			return files[i]
+		}
+	}
+
+	return def
+}
+
+// positionAt returns the position of the key at path in tree. The elements of arrays of tables are indexes in path,
+// and the elements of other arrays are at the position of the array.
+func positionAt(tree *toml.Tree, path []string) (toml.Position, bool) {
+	for len(path) > 0 {
+		key := []string{path[0]}
+		if !tree.HasPath(key) {
+			return toml.Position{}, false
+		}
+
+		pos := tree.GetPositionPath(key)
+		if path = path[1:]; len(path) == 0 {
+			return pos, true
+		}
+
+		switch v := tree.GetPath(key).(type) {
+		case *toml.Tree:
+			tree = v
+		case []*toml.Tree:
+			i, err := strconv.Atoi(path[0])
+			if err != nil || i < 0 || i >= len(v) {
+				return toml.Position{}, false
+			}
+			tree, path = v[i], path[1:]
+		default:
+			return pos, true
+		}
+	}
+
+	return tree.Position(), true
+}
+
+// allows will check if a value of type typ is allowed by s
+func (s *schema) allows(typ string) bool {
+	if len(s.Type) == 0 {
+		return true
+	}
+
+	for _, t := range s.Type {
+		if t == typ || t == "number" && typ == "integer" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// inEnum will check if val is one of the allowed values of s
+func (s *schema) inEnum(val interface{}) bool {
+	for _, e := range s.Enum {
+		ef, eIsNum := toFloat(e)
+		vf, vIsNum := toFloat(val)
+		if eIsNum && vIsNum && ef == vf || fmt.Sprint(e) == fmt.Sprint(val) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// valueType returns the JSON type of a value in a TOML tree.
+func valueType(val interface{}) string {
+	switch val.(type) {
+	case *toml.Tree:
+		return "object"
+	case []*toml.Tree, []interface{}:
+		return "array"
+	case int64:
+		return "integer"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case string, time.Time, toml.LocalDate, toml.LocalDateTime, toml.LocalTime:
+		return "string"
+	}
+
+	return fmt.Sprintf("%T", val)
+}
+
+// toFloat converts the numbers in a TOML tree or a JSON document to float64.
+func toFloat(val interface{}) (float64, bool) {
+	switch v := val.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+
+	return 0, false
+}
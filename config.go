@@ -2,14 +2,14 @@
 package config
 
 import (
-	"flag"
+	"encoding"
+	"errors"
 	"fmt"
-	"os"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 
-	"github.com/fatih/structs"
 	"github.com/pelletier/go-toml"
 )
 
@@ -17,140 +17,427 @@ const (
 	envTag  string = "env"
 	flagTag string = "flag"
 	tomlTag string = "toml"
-)
 
-// Load loads filepath into dst. It also handles "flag" binding.
-func Load(filepath string, dst interface{}) error {
-	tree, err := toml.LoadFile(filepath)
-	if err != nil {
-		return err
-	}
+	descriptionTag string = "description"
+	defaultTag     string = "default"
+	requiredTag    string = "required"
+	enumTag        string = "enum"
+	minTag         string = "min"
+	maxTag         string = "max"
+	aliasesTag     string = "aliases"
+	deprecatedTag  string = "deprecated"
+)
 
-	if err := tree.Unmarshal(dst); err != nil {
-		return err
-	}
+// Load loads filepath into dst. It also handles "env" and "flag" binding. See Loader for the options.
+func Load(filepath string, dst interface{}, opts ...Option) error {
+	return New(opts...).Load(filepath, dst)
+}
 
-	if err := bindEnvVariables(dst); err != nil {
-		return err
-	}
+// LoadDir loads all *.toml files in dir into dst, deep-merging them in lexical order so later files override earlier
+// ones. It also handles "env" and "flag" binding.
+func LoadDir(dir string, dst interface{}, opts ...Option) error {
+	return New(opts...).LoadDir(dir, dst)
+}
 
-	return bindFlags(dst, tree, "")
+// LoadTree loads filepath into a TOML tree, as Load does before unmarshaling it into the destination struct.
+//
+// With WithProfile, the overrides of the active profile are merged over the tree. They are read from the
+// [profile.<name>] table of the file, and from the file with the profile name before its extension (e.g.
+// config.prod.toml for config.toml) if it exists.
+//
+// Files listed in the include directive (`include = ["../common/logging.toml"]`) are merged under the file.
+func LoadTree(filepath string, opts ...Option) (*toml.Tree, error) {
+	return New(opts...).LoadTree(filepath)
 }
 
-// bindEnvVariables will bind CLI flags to their respective elements in dst, defined by the struct-tag "env".
-func bindEnvVariables(dst interface{}) error {
-	fields := structs.Fields(dst)
-	for _, field := range fields {
-		if !field.IsExported() {
+// bindSources will bind environment variables and CLI flags to their respective elements in dst, defined by the
+// struct-tags "env" and "flag" (or their names in src.tags). Each element takes its value from the first source in
+// src.precedence that has one, and from the flag default if none has. The errors of all fields that can't be bound are
+// returned in a MultiError.
+func bindSources(dst interface{}, tree *toml.Tree, fieldPath string, src *sources) error {
+	var errs MultiError
+
+	v := reflect.ValueOf(dst).Elem()
+	plan := planFor(v.Type(), src.tags)
+	for i := range plan.fields {
+		fp := &plan.fields[i]
+		dstElem, ok := fp.field(v)
+		if !ok {
+			continue
+		}
+
+		envKey, flagName := fp.envKey, fp.flagName
+		if src.indexed {
+			envKey, flagName = src.indexedNames(envKey, flagName)
+		}
+
+		if fp.structSlice {
+			errs = errs.append(bindSlice(dstElem, fp, tree, fieldPath, envKey, flagName, src))
+			continue
+		}
+
+		if fp.structMap {
+			errs = errs.append(bindMap(dstElem, fp, tree, fieldPath, envKey, flagName, src))
 			continue
 		}
 
-		tag := field.Tag(envTag)
-		if tag == "" || tag == "-" {
-			ok, dstElem := isNestedStruct(dst, field)
-			if !ok {
+		if envKey == "" && flagName == "" {
+			if fp.iface {
+				if elem, store, ok := ifaceStruct(dstElem); ok {
+					errs = errs.append(bindSources(elem.Addr().Interface(), tree, fp.nestedPath(fieldPath), src))
+					store()
+				}
+
 				continue
 			}
 
-			if err := bindEnvVariables(dstElem.Addr().Interface()); err != nil {
-				return err
+			if !fp.nested {
+				continue
 			}
 
+			path := fp.nestedPath(fieldPath)
+			errs = errs.append(bindSources(nestedStruct(dstElem).Addr().Interface(), tree, path, src))
 			continue
 		}
 
-		fVal, ok := os.LookupEnv(tag)
-		if !ok {
+		tomlKey := fp.nestedPath(fieldPath)
+
+		fileTree := tree
+		if len(fp.index) > 1 {
+			// Promoted from an unexported embedded struct, which go-toml doesn't decode
+			fileTree = nil
+		}
+
+		path := src.fieldPath(fp.nestedPath(fieldPath))
+
+		source, key, fVal, ok := lookupField(src, fp, flagName, envKey, fileTree, tomlKey)
+		if ok && source == SourceFile {
+			// Already unmarshaled
+			src.origins[path] = origin{source: source, key: path}
 			continue
 		}
 
-		if err := setDstElem(dst, field, fVal); err != nil {
-			return err
+		if !ok {
+			key = flagName
+			if flagName == "" || src.indexed {
+				// Indexed flags have no defaults
+				continue
+			}
+
+			fl := src.flags.Lookup(flagName)
+			if fl == nil {
+				errs = append(errs, &FieldError{Path: path, Source: SourceFlag, Key: flagName, Err: errors.New("flag is not defined but given as flag struct tag")})
+				continue
+			}
+			if fp.typ.Kind() == reflect.Ptr {
+				// Pointers to scalars are left nil, so flag defaults don't look like given values
+				continue
+			}
+			source, fVal = SourceDefault, fl.DefValue
+		}
+
+		src.origins[path] = origin{source: source, key: key, value: fVal}
+		if err := setValue(dstElem, fp.name, fVal); err != nil {
+			errs = append(errs, src.fieldError(path, reflect.Value{}, err))
 		}
 	}
-	return nil
+
+	return errs.err()
+}
+
+// lookupSources returns the value of the first source in src.precedence that has one: the given flag flagName, the
+// environment variable envKey, or the field path tomlKey in tree, e.g. "db.port". The value of tomlKey is not returned,
+// as it's already unmarshaled. Empty names and a nil tree are skipped.
+func lookupSources(src *sources, flagName, envKey string, tree *toml.Tree, tomlKey string) (Source, string, bool) {
+	for _, source := range src.precedence {
+		if fVal, ok := lookupSource(src, source, flagName, envKey, tree, tomlKey); ok {
+			return source, fVal, true
+		}
+	}
+
+	return SourceNone, "", false
+}
+
+// lookupSource returns the value of flagName, envKey or tomlKey, whichever is read from source.
+func lookupSource(src *sources, source Source, flagName, envKey string, tree *toml.Tree, tomlKey string) (string, bool) {
+	switch source {
+	case SourceFlag:
+		if flagName != "" && src.isFlagSet(flagName) {
+			return src.flags.Lookup(flagName).Value.String(), true
+		}
+	case SourceEnv:
+		if envKey != "" {
+			return src.lookupEnv(envKey)
+		}
+	case SourceFile:
+		if tree != nil && tomlKey != "" && hasTreePath(tree, tomlKey) {
+			return "", true
+		}
+	}
+
+	return "", false
 }
 
-// bindFlags will bind CLI flags to their respective elements in dst, defined by the struct-tag "flag".
-func bindFlags(dst interface{}, tree *toml.Tree, fieldPath string) error {
-	fields := structs.Fields(dst)
-	for _, field := range fields {
-		if !field.IsExported() {
+// validate will check if the elements in dst defined by the struct-tags "min" and "max" are within their bounds, and,
+// with WithValidation, if the ones defined by the struct-tag "required" are set by any source and the ones defined by
+// the struct-tag "enum" are set to one of the allowed values. The errors of all invalid fields are returned in a
+// MultiError.
+func validate(dst interface{}, fieldPath string, src *sources) error {
+	var errs MultiError
+
+	v := reflect.ValueOf(dst).Elem()
+	plan := planFor(v.Type(), src.tags)
+	for i := range plan.fields {
+		fp := &plan.fields[i]
+		dstElem, ok := fp.field(v)
+		if !ok {
 			continue
 		}
 
-		tag := field.Tag(flagTag)
-		if tag == "" || tag == "-" {
-			ok, dstElem := isNestedStruct(dst, field)
-			if !ok {
-				continue
+		path := fp.nestedPath(fieldPath)
+
+		if src.validation && fp.required && !src.isSet(fp, path) {
+			errs = append(errs, &FieldError{Path: path, Err: errors.New("required but not set")})
+			continue
+		}
+
+		if src.validation && fp.enum != "" {
+			errs = errs.append(checkEnum(dstElem, path, fp.enum, src))
+		}
+
+		if err := checkRange(dstElem, fp.min, fp.max); err != nil {
+			errs = append(errs, src.fieldError(path, dstElem, err))
+		}
+
+		errs = errs.append(validateElems(dstElem, path, src))
+
+		if fp.iface {
+			if elem, _, ok := ifaceStruct(dstElem); ok {
+				errs = errs.append(validate(elem.Addr().Interface(), path, src))
 			}
 
-			var path string
-			if fieldPath != "" {
-				path = fmt.Sprintf("%s.", fieldPath)
+			continue
+		}
+
+		if !fp.nested {
+			continue
+		}
+
+		errs = errs.append(validate(nestedStruct(dstElem).Addr().Interface(), path, src))
+	}
+
+	return errs.err()
+}
+
+// validateElems will validate the elements of dstElem if it is a slice or a map of structs, as nested structs are
+func validateElems(dstElem reflect.Value, path string, src *sources) error {
+	var errs MultiError
+
+	switch {
+	case isStructSliceType(dstElem.Type()):
+		for i := 0; i < dstElem.Len(); i++ {
+			elem := dstElem.Index(i)
+			if elem.Kind() == reflect.Ptr {
+				if elem.IsNil() {
+					continue
+				}
+				elem = elem.Elem()
 			}
 
-			if field.Tag(tomlTag) != "" {
-				path += field.Tag(tomlTag)
-			} else {
-				path += field.Name()
+			errs = errs.append(validate(elem.Addr().Interface(), fmt.Sprintf("%s[%d]", path, i), src))
+		}
+
+	case isStructMapType(dstElem.Type()):
+		keys := dstElem.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+
+		for _, key := range keys {
+			// Map values are not addressable, validate a copy
+			elem := reflect.New(dstElem.Type().Elem()).Elem()
+			elem.Set(dstElem.MapIndex(key))
+			if elem.Kind() == reflect.Ptr {
+				if elem.IsNil() {
+					continue
+				}
+				elem = elem.Elem()
 			}
 
-			if err := bindFlags(dstElem.Addr().Interface(), tree, path); err != nil {
-				return err
+			errs = errs.append(validate(elem.Addr().Interface(), fmt.Sprintf("%s.%s", path, key.String()), src))
+		}
+	}
+
+	return errs.err()
+}
+
+// isRequired will check if the "required" struct-tag is set
+func isRequired(tag string) bool {
+	required, _ := strconv.ParseBool(tag)
+	return required
+}
+
+// isSet will check if the field fp at path was set by any source: the file, an environment variable or a flag, or by
+// a flag default or its "default" struct-tag. Zero values count as set, e.g. `enabled = false` in the file.
+func (src *sources) isSet(fp *fieldPlan, path string) bool {
+	if _, ok := src.origins[path]; ok || fp.hasDefault {
+		return true
+	}
+
+	if fp.nested || fp.structSlice || fp.structMap {
+		// Set if any of its fields is
+		for p := range src.origins {
+			if strings.HasPrefix(p, path+".") || strings.HasPrefix(p, path+"[") {
+				return true
 			}
+		}
+	}
 
-			continue
+	// Fields promoted from unexported embedded structs are not read from the file
+	return len(fp.index) == 1 && hasTreePath(src.tree, path)
+}
+
+// hasTreePath will check if tree has a value at the path of a field, e.g. "servers[0].host", matching keys the way
+// go-toml does.
+func hasTreePath(tree *toml.Tree, path string) bool {
+	var val interface{} = tree
+	for _, name := range strings.Split(path, ".") {
+		index := -1
+		if i := strings.Index(name, "["); i > 0 && strings.HasSuffix(name, "]") {
+			n, err := strconv.Atoi(name[i+1 : len(name)-1])
+			if err != nil {
+				return false
+			}
+			name, index = name[:i], n
 		}
 
-		//	if config struct has "flag" tag:
-		//		if flag is set, use flag value
-		//		else if env has key, use environment value
-		//		else if toml file has key, use toml value
-		//		else use flag default value
+		table, ok := val.(*toml.Tree)
+		if !ok || table == nil {
+			return false
+		}
 
-		useFlagDefaultValue := false
-		if !isFlagSet(tag) {
-			_, envHasKey := os.LookupEnv(field.Tag(envTag))
+		key, found := lookupKey(table, name, name)
+		if !found {
+			return false
+		}
+		val = table.GetPath([]string{key})
 
-			var tomlKey string
-			if fieldPath == "" {
-				tomlKey = field.Tag(tomlTag)
-			} else {
-				tomlKey = fmt.Sprintf("%s.%s", fieldPath, field.Tag(tomlTag))
+		if index >= 0 {
+			elems, ok := val.([]*toml.Tree)
+			if !ok || index >= len(elems) {
+				return false
 			}
+			val = elems[index]
+		}
+	}
+
+	return true
+}
 
-			if envHasKey || tree.Has(strings.ToLower(tomlKey)) {
+// checkEnum will check if the value of the field dstElem at path, or each of its elements if it is a slice or an
+// array, is one of the values in the "enum" struct-tag. Zero values and nil pointers are not checked.
+func checkEnum(dstElem reflect.Value, path string, tag string, src *sources) error {
+	val := reflect.Indirect(dstElem)
+	if !val.IsValid() || val.IsZero() {
+		return nil
+	}
+
+	if val.Kind() == reflect.Slice || val.Kind() == reflect.Array {
+		var errs MultiError
+		for i := 0; i < val.Len(); i++ {
+			elem := reflect.Indirect(val.Index(i))
+			if !elem.IsValid() || elem.IsZero() || isEnumValue(elem, tag) {
 				continue
-			} else {
-				useFlagDefaultValue = true
 			}
+
+			fe := src.fieldError(path, dstElem, fmt.Errorf("should be one of [%v], got '%v'", tag, elem.Interface()))
+			fe.Path = fmt.Sprintf("%s[%d]", path, i)
+			errs = append(errs, fe)
 		}
+		return errs.err()
+	}
 
-		// CLI value
-		if flag.Lookup(tag) == nil {
-			return fmt.Errorf("flag '%v' is not defined but given as flag struct tag in %v.%v", tag, reflect.TypeOf(dst), field.Name())
+	if isEnumValue(val, tag) {
+		return nil
+	}
+
+	return src.fieldError(path, dstElem, fmt.Errorf("should be one of [%v], got '%v'", tag, val.Interface()))
+}
+
+// isEnumValue will check if the value of dstElem is one of the comma separated values in the "enum" struct-tag
+func isEnumValue(dstElem reflect.Value, tag string) bool {
+	val := fmt.Sprint(reflect.Indirect(dstElem).Interface())
+	for _, allowed := range strings.Split(tag, ",") {
+		if strings.TrimSpace(allowed) == val {
+			return true
 		}
+	}
+	return false
+}
 
-		var fVal string
-		if useFlagDefaultValue {
-			fVal = flag.Lookup(tag).DefValue
-		} else {
-			fVal = flag.Lookup(tag).Value.String()
+// checkRange will check if the value of dstElem is within the bounds given by the "min" and "max" struct-tags, which
+// are parsed like the value itself (e.g. "64MiB" for a ByteSize). Zero values are checked like any other, only nil
+// pointers are skipped: fields that should be set are tagged "required".
+func checkRange(dstElem reflect.Value, min, max string) error {
+	if (min == "" && max == "") || (dstElem.Kind() == reflect.Ptr && dstElem.IsNil()) {
+		return nil
+	}
+
+	val := reflect.Indirect(dstElem)
+	if min != "" {
+		cmp, err := compareValue(val, min)
+		if err != nil {
+			return err
 		}
+		if cmp < 0 {
+			return fmt.Errorf("should be at least %v, got '%v'", min, val.Interface())
+		}
+	}
 
-		if err := setDstElem(dst, field, fVal); err != nil {
+	if max != "" {
+		cmp, err := compareValue(val, max)
+		if err != nil {
 			return err
 		}
+		if cmp > 0 {
+			return fmt.Errorf("should be at most %v, got '%v'", max, val.Interface())
+		}
 	}
 
 	return nil
 }
 
-// isNestedStruct will check if destination element or its pointer is struct type
-func isNestedStruct(dst interface{}, field *structs.Field) (bool, reflect.Value) {
-	dstElem := reflect.ValueOf(dst).Elem().FieldByName(field.Name())
+// compareValue compares the number val with bound, converted to the type of val. It returns -1, 0 or 1 if val is less
+// than, equal to or greater than bound.
+func compareValue(val reflect.Value, bound string) (int, error) {
+	b := reflect.New(val.Type()).Elem()
+	if err := setDefaultValue(b, val.Type().Name(), strings.TrimSpace(bound)); err != nil {
+		return 0, fmt.Errorf("invalid bound %q: %v", bound, err)
+	}
+
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return compareOrdered(val.Int() < b.Int(), val.Int() > b.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return compareOrdered(val.Uint() < b.Uint(), val.Uint() > b.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return compareOrdered(val.Float() < b.Float(), val.Float() > b.Float()), nil
+	}
+
+	return 0, fmt.Errorf("%v can't have bounds, as it is not a number", val.Type())
+}
+
+// compareOrdered returns -1 if less, 1 if greater and 0 otherwise
+func compareOrdered(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
+
+// nestedStruct returns the struct dstElem, or the one it points to, allocating it if the pointer is nil
+func nestedStruct(dstElem reflect.Value) reflect.Value {
 	if dstElem.Kind() == reflect.Ptr {
 		if dstElem.IsNil() {
 			// Create new non-nil ptr
@@ -161,17 +448,16 @@ func isNestedStruct(dst interface{}, field *structs.Field) (bool, reflect.Value)
 		dstElem = dstElem.Elem()
 	}
 
-	if dstElem.Kind() != reflect.Struct {
-		return false, dstElem
-	}
-
-	return true, dstElem
+	return dstElem
 }
 
-// setDstElem will convert tag input to its real type
-func setDstElem(dst interface{}, field *structs.Field, fVal string) error {
-	// Destination
-	dstElem := reflect.ValueOf(dst).Elem().FieldByName(field.Name())
+// setValue will convert fVal to the type of dstElem and set it
+func setValue(dstElem reflect.Value, name string, fVal string) error {
+	if dstElem.CanAddr() {
+		if u, ok := dstElem.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(fVal))
+		}
+	}
 
 	// Attempt to convert the tag input depending on type of destination
 	switch dstElem.Kind().String() {
@@ -201,21 +487,17 @@ func setDstElem(dst interface{}, field *structs.Field, fVal string) error {
 		}
 	case "string":
 		dstElem.SetString(fVal)
+	case "ptr":
+		// Allocate, so pointers to scalars are only non-nil if a source sets them
+		elem := reflect.New(dstElem.Type().Elem())
+		if err := setValue(elem.Elem(), name, fVal); err != nil {
+			return err
+		}
+		dstElem.Set(elem)
 
 	default:
-		return fmt.Errorf("unhandled type %v for elem %v", dstElem.Kind().String(), field.Name())
+		return fmt.Errorf("unhandled type %v for elem %v", dstElem.Kind().String(), name)
 	}
 
 	return nil
 }
-
-// isFlagSet will check if flag is set
-func isFlagSet(tag string) bool {
-	flagSet := false
-	flag.Visit(func(fl *flag.Flag) {
-		if fl.Name == tag {
-			flagSet = true
-		}
-	})
-	return flagSet
-}
@@ -1,10 +1,14 @@
 package config
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -882,3 +886,676 @@ func TestLoad_IgnoreUnexportedFields_Flag(t *testing.T) {
 		t.Errorf("mismatch (-want +got):\n%v", diff)
 	}
 }
+
+func TestLoad_ErrorIfRequiredNotSet(t *testing.T) {
+	os.Clearenv()
+	var cfg struct {
+		DB struct {
+			Host string `toml:"host" env:"DB_HOST" required:"true"`
+		} `toml:"database"`
+	}
+
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if err := Load(tmp.Name(), &cfg, WithValidation()); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+
+	os.Setenv("DB_HOST", "localhost")
+
+	if err := Load(tmp.Name(), &cfg, WithValidation()); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if cfg.DB.Host != "localhost" {
+		t.Errorf("got: %v, expected: %v", cfg.DB.Host, "localhost")
+	}
+}
+
+func TestLoad_WithoutValidation(t *testing.T) {
+	// The tags can be shared with other libraries, such as envconfig.
+	var cfg struct {
+		Host  string `toml:"host" required:"true"`
+		Level string `toml:"level" enum:"debug,info"`
+	}
+
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString("level = \"trace\"\n"); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	if err := Load(tmp.Name(), &cfg, WithEnv(nil)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if cfg.Level != "trace" {
+		t.Errorf("got: %v, expected: %v", cfg.Level, "trace")
+	}
+
+	err := Load(tmp.Name(), &cfg, WithEnv(nil), WithValidation())
+
+	var multi MultiError
+	if !errors.As(err, &multi) || len(multi) != 2 {
+		t.Errorf("got: %v, expected errors about host and level", err)
+	}
+}
+
+func TestLoad_RequiredZeroValues(t *testing.T) {
+	type testConfig struct {
+		Enabled bool `toml:"enabled" required:"true"`
+		Retries int  `toml:"retries" env:"RETRIES" required:"true"`
+		Servers []struct {
+			Weight int `toml:"weight" required:"true"`
+		} `toml:"servers"`
+	}
+
+	testcases := []struct {
+		name     string
+		content  string
+		env      map[string]string
+		expected string
+	}{
+		{
+			name:    "set to zero values",
+			content: "enabled = false\nretries = 0\n\n[[servers]]\nweight = 0\n",
+		},
+		{
+			name:    "set by env",
+			content: "enabled = false\n",
+			env:     map[string]string{"RETRIES": "0"},
+		},
+		{
+			name:     "not set",
+			content:  "[[servers]]\n\n[[servers]]\nweight = 1\n",
+			expected: "3 errors: field 'enabled': required but not set; field 'retries': required but not set; field 'servers[0].weight': required but not set",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmp, _ := ioutil.TempFile("", "")
+			defer os.Remove(tmp.Name())
+
+			if _, err := tmp.WriteString(tc.content); err != nil {
+				t.Fatalf("write config file failed: %v", err)
+			}
+
+			var cfg testConfig
+			err := Load(tmp.Name(), &cfg, WithEnv(tc.env), WithValidation())
+			if tc.expected == "" {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				return
+			}
+
+			if got := fmt.Sprint(err); got != tc.expected {
+				t.Errorf("got: %v, expected: %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestLoad_ErrorIfNotEnumValue(t *testing.T) {
+	var cfg struct {
+		Level string `toml:"level" enum:"debug,info"`
+	}
+
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(`level = "trace"`); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	if err := Load(tmp.Name(), &cfg, WithValidation()); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+}
+
+func TestLoad_EnumSlice(t *testing.T) {
+	var cfg struct {
+		Levels []string `toml:"levels" enum:"debug,info"`
+	}
+
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(`levels = ["debug", "info"]`); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	if err := Load(tmp.Name(), &cfg, WithValidation()); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if err := ioutil.WriteFile(tmp.Name(), []byte(`levels = ["debug", "trace"]`), 0644); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	err := Load(tmp.Name(), &cfg, WithValidation())
+	if got, expected := fmt.Sprint(err), "field 'levels[1]': should be one of [debug,info], got 'trace'"; got != expected {
+		t.Errorf("got: %v, expected: %v", got, expected)
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	os.Clearenv()
+	var cfg struct {
+		Name string `toml:"name"`
+		DB   struct {
+			Host string `toml:"host"`
+			Port int    `toml:"port"`
+		} `toml:"database"`
+	}
+
+	dir, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"10-base.toml": `
+name = "base"
+
+[database]
+host = "localhost"
+port = 5432
+`,
+		"20-db.toml": `
+[database]
+host = "db.example.com"
+`,
+		"30-ignored.toml.bak": `name = "ignored"`,
+	}
+
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("write config file failed: %v", err)
+		}
+	}
+
+	if err := LoadDir(dir, &cfg); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if cfg.Name != "base" {
+		t.Errorf("got: %v, expected: %v", cfg.Name, "base")
+	}
+
+	if cfg.DB.Host != "db.example.com" {
+		t.Errorf("got: %v, expected: %v", cfg.DB.Host, "db.example.com")
+	}
+
+	if cfg.DB.Port != 5432 {
+		t.Errorf("got: %v, expected: %v", cfg.DB.Port, 5432)
+	}
+}
+
+func TestLoadDir_ErrorIfNotExist(t *testing.T) {
+	var cfg struct{}
+
+	if err := LoadDir("/nonexistent/conf.d", &cfg); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+}
+
+func TestLoad_WithEnv(t *testing.T) {
+	type tenantConfig struct {
+		Name string `toml:"name"`
+		DB   struct {
+			Host string `toml:"host" env:"DB_HOST"`
+		} `toml:"database"`
+	}
+
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString("name = \"tenant\"\n\n[database]\nhost = \"localhost\"\n[profile.prod]\nname = \"prod\"\n"); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	tenants := map[string]map[string]string{
+		"localhost":       {},
+		"db1.example.com": {"DB_HOST": "db1.example.com"},
+		"db2.example.com": {"DB_HOST": "db2.example.com", "APP_PROFILE": "prod"},
+		"db3.example.com": {"DB_HOST": "db3.example.com"},
+	}
+
+	// Tenants are loaded side by side, the group returns when all of them are done
+	t.Run("group", func(t *testing.T) {
+		for expected, env := range tenants {
+			expected, env := expected, env
+			t.Run(expected, func(t *testing.T) {
+				t.Parallel()
+
+				var cfg tenantConfig
+				if err := Load(tmp.Name(), &cfg, WithEnv(env), WithProfile("APP_PROFILE", "", "")); err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+
+				if cfg.DB.Host != expected {
+					t.Errorf("got: %v, expected: %v", cfg.DB.Host, expected)
+				}
+
+				expectedName := "tenant"
+				if env["APP_PROFILE"] == "prod" {
+					expectedName = "prod"
+				}
+				if cfg.Name != expectedName {
+					t.Errorf("got: %v, expected: %v", cfg.Name, expectedName)
+				}
+			})
+		}
+	})
+}
+
+func TestLoad_WithEnvLookup(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("HOST_NAME", "process.example.com")
+
+	var cfg struct {
+		Hostname string `env:"HOST_NAME"`
+		Port     int    `env:"PORT"`
+	}
+
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	dotenv, _ := ioutil.TempFile("", "")
+	defer os.Remove(dotenv.Name())
+
+	if _, err := dotenv.WriteString("HOST_NAME=dotenv.example.com\nPORT=${BASE_PORT}1\n"); err != nil {
+		t.Fatalf("write dotenv file failed: %v", err)
+	}
+
+	lookupEnv := func(key string) (string, bool) {
+		if key == "BASE_PORT" {
+			return "808", true
+		}
+		return "", false
+	}
+
+	if err := Load(tmp.Name(), &cfg, WithEnvLookup(lookupEnv), WithDotenv(dotenv.Name())); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// The process environment is ignored, and the dotenv file is expanded with the custom lookup
+	if cfg.Hostname != "dotenv.example.com" {
+		t.Errorf("got: %v, expected: %v", cfg.Hostname, "dotenv.example.com")
+	}
+
+	if cfg.Port != 8081 {
+		t.Errorf("got: %v, expected: %v", cfg.Port, 8081)
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	envFlagFile := []Source{SourceEnv, SourceFlag, SourceFile}
+	fileEnvFlag := []Source{SourceFile, SourceEnv, SourceFlag}
+
+	testcases := []struct {
+		precedence []Source
+		flag       bool
+		env        bool
+		toml       bool
+		expected   string
+	}{
+		// Default: flag > env > toml > flag default
+		{nil, true, true, true, "flag"},
+		{nil, true, true, false, "flag"},
+		{nil, true, false, true, "flag"},
+		{nil, false, true, true, "env"},
+		{nil, true, false, false, "flag"},
+		{nil, false, true, false, "env"},
+		{nil, false, false, true, "toml"},
+		{nil, false, false, false, "default"},
+
+		// env > flag > toml > flag default
+		{envFlagFile, true, true, true, "env"},
+		{envFlagFile, true, true, false, "env"},
+		{envFlagFile, true, false, true, "flag"},
+		{envFlagFile, false, true, true, "env"},
+		{envFlagFile, true, false, false, "flag"},
+		{envFlagFile, false, true, false, "env"},
+		{envFlagFile, false, false, true, "toml"},
+		{envFlagFile, false, false, false, "default"},
+
+		// toml > env > flag > flag default
+		{fileEnvFlag, true, true, true, "toml"},
+		{fileEnvFlag, true, true, false, "env"},
+		{fileEnvFlag, true, false, true, "toml"},
+		{fileEnvFlag, false, true, true, "toml"},
+		{fileEnvFlag, true, false, false, "flag"},
+		{fileEnvFlag, false, true, false, "env"},
+		{fileEnvFlag, false, false, true, "toml"},
+		{fileEnvFlag, false, false, false, "default"},
+	}
+
+	for _, tc := range testcases {
+		name := fmt.Sprintf("%v/flag=%v,env=%v,toml=%v", tc.precedence, tc.flag, tc.env, tc.toml)
+		t.Run(name, func(t *testing.T) {
+			var cfg struct {
+				Key string `toml:"key" env:"KEY" flag:"key"`
+			}
+
+			fs := flag.NewFlagSet("tmp", flag.ContinueOnError)
+			_ = fs.String("key", "default", "")
+			var args []string
+			if tc.flag {
+				args = []string{"-key", "flag"}
+			}
+			if err := fs.Parse(args); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			env := map[string]string{}
+			if tc.env {
+				env["KEY"] = "env"
+			}
+
+			tmp, _ := ioutil.TempFile("", "")
+			defer os.Remove(tmp.Name())
+
+			if tc.toml {
+				if _, err := tmp.WriteString(`key = "toml"`); err != nil {
+					t.Fatalf("write config file failed: %v", err)
+				}
+			}
+
+			opts := []Option{WithFlagSet(fs), WithEnv(env)}
+			if tc.precedence != nil {
+				opts = append(opts, WithPrecedence(tc.precedence...))
+			}
+
+			if err := Load(tmp.Name(), &cfg, opts...); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			if cfg.Key != tc.expected {
+				t.Errorf("got: %v, expected: %v", cfg.Key, tc.expected)
+			}
+		})
+	}
+
+	// The file is looked up by the key go-toml decodes the field from.
+	keytestcases := []struct {
+		name       string
+		precedence []Source
+		cfg        interface{}
+		content    string
+		env        map[string]string
+	}{
+		{
+			name: "omitempty",
+			cfg: &struct {
+				Port int `toml:"port,omitempty" flag:"port"`
+			}{},
+			content: "port = 9000\n",
+		},
+		{
+			name: "untagged",
+			cfg: &struct {
+				Port int `flag:"port"`
+			}{},
+			content: "port = 9000\n",
+		},
+		{
+			name: "nested omitempty",
+			cfg: &struct {
+				DB struct {
+					Port int `toml:"port,omitempty" flag:"port"`
+				} `toml:"db,omitempty"`
+			}{},
+			content: "[db]\nport = 9000\n",
+		},
+		{
+			name:       "omitempty over env",
+			precedence: fileEnvFlag,
+			cfg: &struct {
+				Port int `toml:"port,omitempty" env:"PORT" flag:"port"`
+			}{},
+			content: "port = 9000\n",
+			env:     map[string]string{"PORT": "8000"},
+		},
+	}
+
+	for _, tc := range keytestcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("tmp", flag.ContinueOnError)
+			_ = fs.Int("port", 80, "")
+			if err := fs.Parse(nil); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			tmp, _ := ioutil.TempFile("", "")
+			defer os.Remove(tmp.Name())
+
+			if _, err := tmp.WriteString(tc.content); err != nil {
+				t.Fatalf("write config file failed: %v", err)
+			}
+
+			opts := []Option{WithFlagSet(fs), WithEnv(tc.env)}
+			if tc.precedence != nil {
+				opts = append(opts, WithPrecedence(tc.precedence...))
+			}
+
+			if err := Load(tmp.Name(), tc.cfg, opts...); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			if got := fmt.Sprint(tc.cfg); !strings.Contains(got, "9000") {
+				t.Errorf("got: %v, expected the file value 9000", got)
+			}
+		})
+	}
+}
+
+func TestLoad_ErrorIfInvalidPrecedence(t *testing.T) {
+	var cfg struct{}
+
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	testcases := [][]Source{
+		{SourceEnv, SourceFile},
+		{SourceEnv, SourceEnv, SourceFile},
+		{SourceEnv, SourceFlag, SourceFile, Source(42)},
+		{SourceNone, SourceEnv, SourceFlag, SourceFile},
+	}
+
+	for _, precedence := range testcases {
+		if err := Load(tmp.Name(), &cfg, WithPrecedence(precedence...)); err == nil {
+			t.Errorf("expected error for precedence %v, got nil", precedence)
+		}
+	}
+}
+
+type EmbeddedTestHTTP struct {
+	Host string `toml:"host" env:"HTTP_HOST"`
+	Port int    `toml:"port" flag:"http-port" required:"true"`
+}
+
+type EmbeddedTestLogging struct {
+	Level string `toml:"level" enum:"debug,info"`
+}
+
+type embeddedTestConfig struct {
+	EmbeddedTestHTTP
+	EmbeddedTestLogging `toml:"logging"` // opt out, read from the [logging] table
+	Name                string           `toml:"name"`
+}
+
+func TestLoad_EmbeddedSquashed(t *testing.T) {
+	fs := flag.NewFlagSet("tmp", flag.ContinueOnError)
+	_ = fs.Int("http-port", 80, "")
+	if err := fs.Parse(nil); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(`
+name = "example"
+host = "localhost"
+port = 8080
+
+[logging]
+level = "debug"
+`); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	var cfg embeddedTestConfig
+	env := map[string]string{"HTTP_HOST": "example.com"}
+	if err := Load(tmp.Name(), &cfg, WithFlagSet(fs), WithEnv(env), WithStrict()); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := embeddedTestConfig{
+		EmbeddedTestHTTP:    EmbeddedTestHTTP{Host: "example.com", Port: 8080},
+		EmbeddedTestLogging: EmbeddedTestLogging{Level: "debug"},
+		Name:                "example",
+	}
+	if diff := cmp.Diff(expected, cfg); diff != "" {
+		t.Errorf("(-want +got):\n%v", diff)
+	}
+}
+
+func TestLoad_EmbeddedErrorPaths(t *testing.T) {
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString("port = 8080\n\n[logging]\nlevel = \"trace\"\n"); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	var cfg embeddedTestConfig
+	fs := flag.NewFlagSet("tmp", flag.ContinueOnError)
+	_ = fs.Int("http-port", 80, "")
+
+	err := Load(tmp.Name(), &cfg, WithFlagSet(fs), WithEnv(nil), WithValidation())
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+
+	if !strings.Contains(err.Error(), "'logging.level'") {
+		t.Errorf("got: %v, expected error about logging.level", err)
+	}
+}
+
+func TestLoad_EmbeddedPointer(t *testing.T) {
+	type testConfig struct {
+		*EmbeddedTestHTTP
+		Name string `toml:"name"`
+	}
+
+	fs := flag.NewFlagSet("tmp", flag.ContinueOnError)
+	_ = fs.Int("http-port", 80, "")
+	if err := fs.Parse(nil); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	// go-toml decodes embedded pointers from a table named after the type
+	if _, err := tmp.WriteString("name = \"example\"\n\n[EmbeddedTestHTTP]\nhost = \"filehost\"\nport = 8080\n"); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	var cfg testConfig
+	if err := Load(tmp.Name(), &cfg, WithFlagSet(fs), WithEnv(nil), WithStrict()); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := testConfig{EmbeddedTestHTTP: &EmbeddedTestHTTP{Host: "filehost", Port: 8080}, Name: "example"}
+	if diff := cmp.Diff(expected, cfg); diff != "" {
+		t.Errorf("(-want +got):\n%v", diff)
+	}
+
+	b, err := JSONSchema(&testConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	var schema struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(b, &schema); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if _, ok := schema.Properties["EmbeddedTestHTTP"]; !ok {
+		t.Errorf("expected the embedded pointer as a table in the schema, got: %s", b)
+	}
+	if _, ok := schema.Properties["host"]; ok {
+		t.Errorf("expected no flattened host key in the schema, got: %s", b)
+	}
+}
+
+func TestLoad_PointerScalars(t *testing.T) {
+	type testConfig struct {
+		Workers *int     `toml:"workers" env:"WORKERS"`
+		Ratio   *float64 `toml:"ratio" env:"RATIO"`
+		Debug   *bool    `toml:"debug" flag:"debug"`
+		Name    *string  `toml:"name" flag:"name"`
+		Retries *int     `toml:"retries" env:"RETRIES" required:"true"`
+		Timeout *uint    `toml:"timeout"`
+	}
+
+	fs := flag.NewFlagSet("tmp", flag.ContinueOnError)
+	_ = fs.Bool("debug", true, "")
+	_ = fs.String("name", "default", "")
+	if err := fs.Parse([]string{"-name", "example"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString("retries = 3\n"); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	var cfg testConfig
+	env := map[string]string{"WORKERS": "0"}
+	if err := Load(tmp.Name(), &cfg, WithFlagSet(fs), WithEnv(env)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	workers, name, retries := 0, "example", 3
+	expected := testConfig{
+		Workers: &workers, // set to zero
+		Name:    &name,
+		Retries: &retries,
+	}
+	if diff := cmp.Diff(expected, cfg); diff != "" {
+		t.Errorf("(-want +got):\n%v", diff)
+	}
+
+	if _, err := Marshal(&cfg); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestLoad_ErrorIfPointerScalarNotValid(t *testing.T) {
+	type testConfig struct {
+		Workers *int `toml:"workers" env:"WORKERS"`
+	}
+
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	var cfg testConfig
+	env := map[string]string{"WORKERS": "many"}
+	if err := Load(tmp.Name(), &cfg, WithFlagSet(flag.NewFlagSet("tmp", flag.ContinueOnError)), WithEnv(env)); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+
+	if cfg.Workers != nil {
+		t.Errorf("got: %v, expected: nil", *cfg.Workers)
+	}
+}
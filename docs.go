@@ -0,0 +1,97 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+// GenerateMarkdown writes a Markdown table documenting every setting of cfg to w: its path in the config file,
+// environment variable, flag, type, default value, whether it is required and its "description" tag.
+//
+// Default values are found the same way GenerateSample does.
+func GenerateMarkdown(w io.Writer, cfg interface{}) error {
+	v, err := structValue(cfg)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("| TOML | Env | Flag | Type | Default | Required | Description |\n")
+	buf.WriteString("|------|-----|------|------|---------|----------|-------------|\n")
+
+	for _, s := range settings(v, nil, true) {
+		def, err := defaultValue(s.field, s.value)
+		if err != nil {
+			return fmt.Errorf("%v: %v", s.field.Name, err)
+		}
+
+		repr, err := toml.ValueStringRepresentation(def, "", "", toml.OrderAlphabetical, false)
+		if err != nil {
+			return err
+		}
+
+		var env, fl string
+		if tag := s.field.Tag.Get(envTag); tag != "" && tag != "-" {
+			env = tag
+		}
+		if tag := s.field.Tag.Get(flagTag); tag != "" && tag != "-" {
+			fl = "-" + tag
+		}
+
+		required := "no"
+		if isRequired(s.field.Tag.Get(requiredTag)) {
+			required = "yes"
+		}
+
+		cells := []string{
+			markdownCode(markdownPath(s.tomlPath)),
+			markdownCode(env),
+			markdownCode(fl),
+			markdownCode(s.field.Type.String()),
+			markdownCode(repr),
+			required,
+			markdownText(s.field.Tag.Get(descriptionTag)),
+		}
+
+		buf.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// markdownPath joins the keys of path, appending the "[]" keys of arrays of tables to the previous key.
+func markdownPath(path []string) string {
+	var b strings.Builder
+	for i, k := range path {
+		if i > 0 && k != "[]" {
+			b.WriteString(".")
+		}
+
+		if k == "[]" {
+			b.WriteString(k)
+		} else {
+			b.WriteString(quoteTomlKey(k))
+		}
+	}
+	return b.String()
+}
+
+// markdownCode formats s as inline code, escaped to be used in a table cell.
+func markdownCode(s string) string {
+	if s == "" {
+		return ""
+	}
+
+	return "`" + strings.ReplaceAll(s, "|", `\|`) + "`"
+}
+
+// markdownText escapes s to be used in a table cell.
+func markdownText(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerateMarkdown(t *testing.T) {
+	fs := flag.NewFlagSet("tmp", flag.ExitOnError)
+	_ = fs.Int("port", 8080, "")
+	flag.CommandLine = fs
+
+	var cfg struct {
+		Name     string        `toml:"name" env:"NAME" required:"true" description:"Name of the service."`
+		Port     int           `toml:"-" flag:"port" description:"Port | address."`
+		Interval time.Duration `toml:"interval" default:"5s"`
+		DB       *struct {
+			Host string `toml:"host" env:"DB_HOST" description:"Database host.\nDefaults to localhost."`
+		} `toml:"database"`
+		Servers []struct {
+			Host string `toml:"host"`
+		} `toml:"servers"`
+	}
+
+	var buf bytes.Buffer
+	if err := GenerateMarkdown(&buf, &cfg); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := "| TOML | Env | Flag | Type | Default | Required | Description |\n" +
+		"|------|-----|------|------|---------|----------|-------------|\n" +
+		"| `name` | `NAME` |  | `string` | `\"\"` | yes | Name of the service. |\n" +
+		"|  |  | `-port` | `int` | `8080` | no | Port \\| address. |\n" +
+		"| `interval` |  |  | `time.Duration` | `\"5s\"` | no |  |\n" +
+		"| `database.host` | `DB_HOST` |  | `string` | `\"\"` | no | Database host.<br>Defaults to localhost. |\n" +
+		"| `servers[].host` |  |  | `string` | `\"\"` | no |  |\n"
+
+	if diff := cmp.Diff(expected, buf.String()); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%v", diff)
+	}
+}
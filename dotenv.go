@@ -0,0 +1,318 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// readDotenv reads the variables defined in the dotenv file at path. Values can reference the environment, or
+// variables defined earlier in the file, as $VAR, ${VAR} or ${VAR:-default}. lookupEnv is used to resolve the
+// environment, which takes precedence over the variables in the file.
+func readDotenv(path string, lookupEnv func(string) (string, bool)) (map[string]string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	vars, err := parseDotenv(string(b), lookupEnv)
+	if err != nil {
+		return nil, fmt.Errorf("%v:%v", path, err)
+	}
+
+	return vars, nil
+}
+
+// dotenvParser parses the contents of a dotenv file.
+type dotenvParser struct {
+	src  string
+	pos  int
+	line int
+
+	vars      map[string]string
+	lookupEnv func(string) (string, bool)
+}
+
+// parseDotenv parses src, which is in the dotenv format:
+//
+//	# comment
+//	export KEY=value
+//	KEY=unquoted value # comment
+//	KEY='literal value, may span
+//	multiple lines'
+//	KEY="value with \n escapes and ${EXPANSION}, may span
+//	multiple lines"
+func parseDotenv(src string, lookupEnv func(string) (string, bool)) (map[string]string, error) {
+	p := &dotenvParser{
+		src:       strings.ReplaceAll(src, "\r\n", "\n"),
+		line:      1,
+		vars:      make(map[string]string),
+		lookupEnv: lookupEnv,
+	}
+
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.src) {
+			return p.vars, nil
+		}
+
+		if err := p.parseLine(); err != nil {
+			return nil, fmt.Errorf("%v: %v", p.line, err)
+		}
+	}
+}
+
+// skipBlank skips whitespace, empty lines and comment lines.
+func (p *dotenvParser) skipBlank() {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == '\n':
+			p.line++
+			p.pos++
+		case c == ' ' || c == '\t':
+			p.pos++
+		case c == '#':
+			p.skipLine()
+		default:
+			return
+		}
+	}
+}
+
+// skipLine skips to the beginning of the next line.
+func (p *dotenvParser) skipLine() {
+	for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+		p.pos++
+	}
+}
+
+// skipSpaces skips spaces and tabs on the current line.
+func (p *dotenvParser) skipSpaces() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// parseLine parses a single variable definition.
+func (p *dotenvParser) parseLine() error {
+	if strings.HasPrefix(p.src[p.pos:], "export ") {
+		p.pos += len("export ")
+		p.skipSpaces()
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) && isDotenvKeyChar(p.src[p.pos]) {
+		p.pos++
+	}
+	key := p.src[start:p.pos]
+	if key == "" {
+		return fmt.Errorf("unexpected character %q, expected variable name", p.src[p.pos])
+	}
+
+	p.skipSpaces()
+	if p.pos >= len(p.src) || p.src[p.pos] != '=' {
+		return fmt.Errorf("expected '=' after %v", key)
+	}
+	p.pos++
+	p.skipSpaces()
+
+	var (
+		value string
+		err   error
+	)
+	switch {
+	case p.pos < len(p.src) && p.src[p.pos] == '\'':
+		value, err = p.parseSingleQuoted()
+	case p.pos < len(p.src) && p.src[p.pos] == '"':
+		value, err = p.parseDoubleQuoted()
+	default:
+		value = p.parseUnquoted()
+	}
+	if err != nil {
+		return err
+	}
+
+	// Only a comment can follow a value
+	p.skipSpaces()
+	if p.pos < len(p.src) && p.src[p.pos] != '\n' && p.src[p.pos] != '#' {
+		return fmt.Errorf("unexpected character %q after value of %v", p.src[p.pos], key)
+	}
+	p.skipLine()
+
+	p.vars[key] = value
+	return nil
+}
+
+// parseSingleQuoted parses a literal value, without escapes or expansion.
+func (p *dotenvParser) parseSingleQuoted() (string, error) {
+	p.pos++ // opening quote
+
+	end := strings.IndexByte(p.src[p.pos:], '\'')
+	if end < 0 {
+		return "", fmt.Errorf("unterminated single-quoted value")
+	}
+
+	value := p.src[p.pos : p.pos+end]
+	p.line += strings.Count(value, "\n")
+	p.pos += end + 1
+	return value, nil
+}
+
+// parseDoubleQuoted parses a value with escapes and expansion.
+func (p *dotenvParser) parseDoubleQuoted() (string, error) {
+	p.pos++ // opening quote
+
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == '"':
+			p.pos++
+			return b.String(), nil
+
+		case c == '\\' && p.pos+1 < len(p.src):
+			p.pos++
+			switch e := p.src[p.pos]; e {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\', '$':
+				b.WriteByte(e)
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(e)
+			}
+			p.pos++
+
+		case c == '$':
+			b.WriteString(p.parseExpansion())
+
+		default:
+			if c == '\n' {
+				p.line++
+			}
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+
+	return "", fmt.Errorf("unterminated double-quoted value")
+}
+
+// parseUnquoted parses a value up to the end of the line or an inline comment, with expansion.
+func (p *dotenvParser) parseUnquoted() string {
+	var b strings.Builder
+	for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+		c := p.src[p.pos]
+		if c == '#' && (p.pos == 0 || p.src[p.pos-1] == ' ' || p.src[p.pos-1] == '\t') {
+			break
+		}
+
+		if c == '$' {
+			b.WriteString(p.parseExpansion())
+			continue
+		}
+
+		b.WriteByte(c)
+		p.pos++
+	}
+
+	return strings.TrimRight(b.String(), " \t")
+}
+
+// parseExpansion parses $VAR, ${VAR} or ${VAR:-default} and returns its value.
+func (p *dotenvParser) parseExpansion() string {
+	p.pos++ // $
+
+	if p.pos < len(p.src) && p.src[p.pos] == '{' {
+		end := strings.IndexByte(p.src[p.pos:], '}')
+		if end < 0 {
+			return "$"
+		}
+
+		expr := p.src[p.pos+1 : p.pos+end]
+		p.pos += end + 1
+
+		name, def := expr, ""
+		if i := strings.Index(expr, ":-"); i >= 0 {
+			name, def = expr[:i], expr[i+2:]
+		}
+
+		if value := p.lookup(name); value != "" {
+			return value
+		}
+		return def
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) && isDotenvKeyChar(p.src[p.pos]) && p.src[p.pos] != '.' && p.src[p.pos] != '-' {
+		p.pos++
+	}
+
+	if start == p.pos {
+		return "$"
+	}
+
+	return p.lookup(p.src[start:p.pos])
+}
+
+// lookup returns the value of name from the environment, or from the variables defined so far.
+func (p *dotenvParser) lookup(name string) string {
+	if value, ok := p.lookupEnv(name); ok {
+		return value
+	}
+
+	return p.vars[name]
+}
+
+// isDotenvKeyChar will check if c is allowed in a variable name
+func isDotenvKeyChar(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '.' || c == '-'
+}
+
+// dotenvLookup returns a lookup function for env, falling back to the variables in the given dotenv files, and a function
+// listing the names of the variables, if envNames can list env. Variables in later files override earlier ones. Missing
+// files are skipped.
+func dotenvLookup(lookupEnv func(string) (string, bool), envNames func() []string, files []string) (func(string) (string, bool), func() []string, error) {
+	vars := make(map[string]string)
+	for _, file := range files {
+		fileVars, err := readDotenv(file, lookupEnv)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for k, v := range fileVars {
+			vars[k] = v
+		}
+	}
+
+	lookup := func(key string) (string, bool) {
+		if value, ok := lookupEnv(key); ok {
+			return value, true
+		}
+
+		value, ok := vars[key]
+		return value, ok
+	}
+
+	if envNames == nil {
+		return lookup, nil, nil
+	}
+
+	names := func() []string {
+		result := envNames()
+		for k := range vars {
+			result = append(result, k)
+		}
+		return result
+	}
+
+	return lookup, names, nil
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseDotenv(t *testing.T) {
+	env := map[string]string{"HOME": "/home/user"}
+	lookupEnv := func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+
+	src := `# comment
+PLAIN=value
+export EXPORTED=exported
+  SPACES = spaced value   # trailing comment
+HASH=a#b
+SINGLE='literal $HOME \n'
+DOUBLE="escaped \"quote\"\tand\nnewline"
+MULTI="first
+second"
+MULTI_SINGLE='first
+second'
+EXPANDED=$HOME/bin
+BRACED=${HOME}/bin
+DEFAULT=${MISSING:-fallback}
+PREVIOUS=${PLAIN}-2
+ESCAPED_DOLLAR="\$HOME"
+EMPTY=
+`
+
+	got, err := parseDotenv(src, lookupEnv)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := map[string]string{
+		"PLAIN":          "value",
+		"EXPORTED":       "exported",
+		"SPACES":         "spaced value",
+		"HASH":           "a#b",
+		"SINGLE":         `literal $HOME \n`,
+		"DOUBLE":         "escaped \"quote\"\tand\nnewline",
+		"MULTI":          "first\nsecond",
+		"MULTI_SINGLE":   "first\nsecond",
+		"EXPANDED":       "/home/user/bin",
+		"BRACED":         "/home/user/bin",
+		"DEFAULT":        "fallback",
+		"PREVIOUS":       "value-2",
+		"ESCAPED_DOLLAR": "$HOME",
+		"EMPTY":          "",
+	}
+
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("(-want +got):\n%v", diff)
+	}
+}
+
+func TestParseDotenv_Errors(t *testing.T) {
+	testcases := map[string]string{
+		"A B":                   "1: expected '=' after A",
+		"OK=1\n=value":          "2: unexpected character '=', expected variable name",
+		"A='unterminated":       "1: unterminated single-quoted value",
+		"A=\"unterminated\nx":   "2: unterminated double-quoted value",
+		"A=\"quoted\" trailing": "1: unexpected character 't' after value of A",
+	}
+
+	for src, expected := range testcases {
+		_, err := parseDotenv(src, os.LookupEnv)
+		if err == nil || err.Error() != expected {
+			t.Errorf("got: %v, expected: %v", err, expected)
+		}
+	}
+}
+
+func TestLoad_Dotenv(t *testing.T) {
+	os.Clearenv()
+	flag.CommandLine = flag.NewFlagSet("tmp", flag.ExitOnError)
+
+	var cfg struct {
+		Host string `env:"HOST"`
+		Port int    `env:"PORT"`
+		User string `env:"USER"`
+	}
+
+	dir, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(dir)
+
+	dotenv := filepath.Join(dir, ".env")
+	if err := ioutil.WriteFile(dotenv, []byte("HOST=dotenv.example.com\nPORT=8080\nUSER=${LOGNAME}\n"), 0644); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	os.Setenv("HOST", "env.example.com")
+	os.Setenv("LOGNAME", "alice")
+
+	if err := Load(tmp.Name(), &cfg, WithDotenv(dotenv, filepath.Join(dir, ".env.local"))); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// Real environment variables take precedence
+	if cfg.Host != "env.example.com" {
+		t.Errorf("got: %v, expected: %v", cfg.Host, "env.example.com")
+	}
+
+	if cfg.Port != 8080 {
+		t.Errorf("got: %v, expected: %v", cfg.Port, 8080)
+	}
+
+	if cfg.User != "alice" {
+		t.Errorf("got: %v, expected: %v", cfg.User, "alice")
+	}
+
+	// The process environment is not modified
+	if _, ok := os.LookupEnv("PORT"); ok {
+		t.Errorf("expected PORT not to be set in the environment")
+	}
+}
+
+func TestLoad_ErrorIfInvalidDotenv(t *testing.T) {
+	var cfg struct {
+		Host string `env:"HOST"`
+	}
+
+	dotenv, _ := ioutil.TempFile("", "")
+	defer os.Remove(dotenv.Name())
+
+	if _, err := dotenv.WriteString("HOST='unterminated\n"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if err := Load(tmp.Name(), &cfg, WithDotenv(dotenv.Name())); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
@@ -0,0 +1,307 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+// FieldError is an error in the value of a field, such as a value that can't be parsed or that is not valid. It
+// records where the value was read from, if it is known.
+type FieldError struct {
+	// Path is the path of the field in the config file, e.g. "database.port" or "servers[0].host".
+	Path string
+
+	// Source is the source of Value, and Key is its name there: the environment variable, the flag or the toml key.
+	// Source is SourceNone and Key is empty if the value isn't read from a source, e.g. for required fields that are
+	// not set, or for fields without "env" and "flag" struct-tags.
+	Source Source
+	Key    string
+
+	// Value is the raw value, as read from the source.
+	Value string
+
+	// Err is the cause.
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("field '%v': %v", e.Path, e.Err)
+	}
+	return fmt.Sprintf("field '%v' (%v %v): %v", e.Path, e.Source, e.Key, e.Err)
+}
+
+// Unwrap returns the cause of e.
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// FileError is an error at a position in a config file, such as a syntax error or a value of the wrong type. Its
+// message has an excerpt of the line, with the column underlined:
+//
+//	config.toml:4:3: Can't convert http(string) to int
+//	 4 |   port = "http"
+//	   |   ^
+type FileError struct {
+	File   string
+	Line   int
+	Column int
+
+	// Excerpt is the numbered line of the error with a caret under the column, empty if the file can't be read.
+	Excerpt string
+
+	// Err is the cause.
+	Err error
+}
+
+func (e *FileError) Error() string {
+	msg := fmt.Sprintf("%v:%d:%d: %v", e.File, e.Line, e.Column, e.Err)
+	if e.Excerpt == "" {
+		return msg
+	}
+	return msg + "\n" + e.Excerpt
+}
+
+// Unwrap returns the cause of e.
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// newFileError returns a FileError for the go-toml error err in file. It returns err if it has no position.
+func newFileError(file string, err error) error {
+	pos, msg, ok := splitPosition(err)
+	if !ok {
+		return err
+	}
+	return fileErrorAt(file, pos, msg)
+}
+
+// fileErrorAt returns a FileError for msg at pos in file.
+func fileErrorAt(file string, pos toml.Position, msg string) *FileError {
+	fe := &FileError{File: file, Line: pos.Line, Column: pos.Col, Err: errors.New(msg)}
+	if b, err := ioutil.ReadFile(file); err == nil {
+		fe.Excerpt = excerpt(b, pos.Line, pos.Col)
+	}
+	return fe
+}
+
+// isFileError returns true if err is a FileError, which has the path of the file in its message.
+func isFileError(err error) bool {
+	var fe *FileError
+	return errors.As(err, &fe)
+}
+
+// splitPosition splits the position off the message of a go-toml error, e.g. "(4, 3): Can't convert ...".
+func splitPosition(err error) (toml.Position, string, bool) {
+	msg := err.Error()
+	end := strings.Index(msg, "): ")
+	if !strings.HasPrefix(msg, "(") || end < 0 {
+		return toml.Position{}, "", false
+	}
+
+	var pos toml.Position
+	if _, err := fmt.Sscanf(msg[:end+1], "(%d, %d)", &pos.Line, &pos.Col); err != nil || pos.Invalid() {
+		return toml.Position{}, "", false
+	}
+
+	return pos, msg[end+3:], true
+}
+
+// excerpt returns line of text, numbered, with a caret under col. Tabs before col are kept under the line, so that the
+// caret lines up with it however they are displayed.
+func excerpt(text []byte, line, col int) string {
+	lines := strings.Split(string(text), "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+
+	src := strings.TrimRight(lines[line-1], "\r")
+	num := strconv.Itoa(line)
+
+	var indent strings.Builder
+	for i, r := range []rune(src) {
+		if i >= col-1 {
+			break
+		}
+		if r == '\t' {
+			indent.WriteRune('\t')
+		} else {
+			indent.WriteRune(' ')
+		}
+	}
+
+	return fmt.Sprintf(" %s | %s\n %s | %s^", num, src, strings.Repeat(" ", len(num)), indent.String())
+}
+
+// locateKey returns msg, the error of the key of tree at path, as a FileError in the config file that the key was read
+// from. It returns msg without a position if the key can't be located.
+func (src *sources) locateKey(tree *toml.Tree, path []string, msg string) error {
+	pos, ok := positionOf(tree, path)
+	if !ok {
+		return errors.New(msg)
+	}
+
+	// Files are merged in order, so the value is from the last file that has it, possibly under a profile table.
+	for i := len(src.files) - 1; i >= 0; i-- {
+		ft, ferr := toml.LoadFile(src.files[i])
+		if ferr != nil {
+			continue
+		}
+
+		found := false
+		walkTree(ft, nil, func(p []string, at toml.Position) bool {
+			found = at == pos && hasSuffix(p, path)
+			return !found
+		})
+		if found {
+			return fileErrorAt(src.files[i], pos, msg)
+		}
+	}
+
+	return errors.New(msg)
+}
+
+// pathAt returns the path of the key of tree at pos.
+func pathAt(tree *toml.Tree, pos toml.Position) ([]string, bool) {
+	var path []string
+	walkTree(tree, nil, func(p []string, at toml.Position) bool {
+		if at == pos {
+			path = p
+		}
+		return path == nil
+	})
+	return path, path != nil
+}
+
+// positionOf returns the position of the key of tree at path.
+func positionOf(tree *toml.Tree, path []string) (toml.Position, bool) {
+	var pos toml.Position
+	walkTree(tree, nil, func(p []string, at toml.Position) bool {
+		if reflect.DeepEqual(p, path) {
+			pos = at
+		}
+		return pos.Invalid()
+	})
+	return pos, !pos.Invalid()
+}
+
+// walkTree calls fn with the path and position of every key of tree, under path, until it returns false. The elements
+// of arrays of tables are in the path as "[i]". It returns false if the walk was stopped.
+func walkTree(tree *toml.Tree, path []string, fn func(path []string, pos toml.Position) bool) bool {
+	for _, key := range tree.Keys() {
+		keyPath := append(path[:len(path):len(path)], key)
+		if !fn(keyPath, tree.GetPositionPath([]string{key})) {
+			return false
+		}
+
+		switch v := tree.GetPath([]string{key}).(type) {
+		case *toml.Tree:
+			if !walkTree(v, keyPath, fn) {
+				return false
+			}
+		case []*toml.Tree:
+			for i, elem := range v {
+				if !walkTree(elem, append(keyPath[:len(keyPath):len(keyPath)], fmt.Sprintf("[%d]", i)), fn) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// hasSuffix returns true if path ends with suffix.
+func hasSuffix(path, suffix []string) bool {
+	return len(path) >= len(suffix) && reflect.DeepEqual(path[len(path)-len(suffix):], suffix)
+}
+
+// MultiError is a list of errors, such as the FieldErrors of all invalid fields of a config. errors.Is and errors.As
+// match the errors in the list.
+type MultiError []error
+
+func (m MultiError) Error() string {
+	if len(m) == 1 {
+		return m[0].Error()
+	}
+
+	msgs := make([]string, 0, len(m))
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("%d errors: %s", len(m), strings.Join(msgs, "; "))
+}
+
+// Is will check if any error in m matches target
+func (m MultiError) Is(target error) bool {
+	for _, err := range m {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first error in m that matches target, and sets target to it.
+func (m MultiError) As(target interface{}) bool {
+	for _, err := range m {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// append adds err to m, flattening the errors of a MultiError. Nil errors are skipped.
+func (m MultiError) append(err error) MultiError {
+	var multi MultiError
+	switch {
+	case err == nil:
+		return m
+	case errors.As(err, &multi):
+		return append(m, multi...)
+	}
+	return append(m, err)
+}
+
+// err returns m, or nil if it is empty.
+func (m MultiError) err() error {
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+// fieldPath returns the path of a field of the element of src, given its path in the element.
+func (src *sources) fieldPath(path string) string {
+	switch {
+	case src.path == "":
+		return path
+	case path == "":
+		return src.path
+	}
+	return fmt.Sprintf("%s.%s", src.path, path)
+}
+
+// fieldError returns a FieldError for the field at path, with the source its value was bound from, if any. Values
+// read from the file are already unmarshaled into dstElem, which is formatted as their raw value.
+func (src *sources) fieldError(path string, dstElem reflect.Value, err error) *FieldError {
+	fe := &FieldError{Path: path, Err: err}
+
+	o, ok := src.origins[path]
+	if !ok {
+		return fe
+	}
+
+	fe.Source, fe.Key, fe.Value = o.source, o.key, o.value
+	if o.source == SourceFile && dstElem.IsValid() {
+		fe.Value = fmt.Sprint(reflect.Indirect(dstElem).Interface())
+	}
+
+	return fe
+}
@@ -0,0 +1,341 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoad_FieldErrors(t *testing.T) {
+	type server struct {
+		Host string `toml:"host" env:"HOST"`
+		Port int    `toml:"port" env:"PORT"`
+	}
+
+	type testConfig struct {
+		Name    string   `toml:"name" required:"true"`
+		Port    int      `toml:"port" env:"PORT" flag:"port"`
+		Workers int      `toml:"workers" flag:"workers"`
+		Level   string   `toml:"level" env:"LEVEL" enum:"debug,info"`
+		Servers []server `toml:"servers" env:"APP_SERVERS"`
+	}
+
+	fs := flag.NewFlagSet("tmp", flag.ContinueOnError)
+	_ = fs.String("port", "80", "")
+	_ = fs.String("workers", "many", "")
+	if err := fs.Parse(nil); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString("[[servers]]\nhost = \"10.0.0.1\"\n"); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	env := map[string]string{
+		"PORT":               "http",
+		"LEVEL":              "trace",
+		"APP_SERVERS_0_PORT": "-",
+	}
+
+	var cfg testConfig
+	err := Load(tmp.Name(), &cfg, WithFlagSet(fs), WithEnv(env))
+
+	var multi MultiError
+	if !errors.As(err, &multi) {
+		t.Fatalf("got: %v, expected a MultiError", err)
+	}
+
+	var paths []string
+	for _, e := range multi {
+		var fe *FieldError
+		if !errors.As(e, &fe) {
+			t.Fatalf("got: %v, expected a FieldError", e)
+		}
+		paths = append(paths, fe.Path)
+	}
+
+	// Validation is skipped if binding fails
+	if diff := cmp.Diff([]string{"port", "workers", "servers[0].port"}, paths); diff != "" {
+		t.Errorf("(-want +got):\n%v", diff)
+	}
+
+	var fe *FieldError
+	if !errors.As(err, &fe) {
+		t.Fatalf("got: %v, expected a FieldError", err)
+	}
+
+	if fe.Path != "port" || fe.Source != SourceEnv || fe.Key != "PORT" || fe.Value != "http" {
+		t.Errorf("got: %+v, expected the env PORT=http", fe)
+	}
+
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("got: %v, expected to wrap strconv.ErrSyntax", err)
+	}
+
+	expected := `3 errors: field 'port' (env PORT): strconv.ParseInt: parsing "http": invalid syntax; ` +
+		`field 'workers' (default workers): strconv.ParseInt: parsing "many": invalid syntax; ` +
+		`field 'servers[0].port' (env APP_SERVERS_0_PORT): strconv.ParseInt: parsing "-": invalid syntax`
+	if err.Error() != expected {
+		t.Errorf("got: %v, expected: %v", err, expected)
+	}
+}
+
+func TestLoad_ValidationErrors(t *testing.T) {
+	type testConfig struct {
+		Name    string `toml:"name" required:"true"`
+		Level   string `toml:"level" env:"LEVEL" enum:"debug,info"`
+		Workers int    `toml:"workers" flag:"workers" max:"64"`
+	}
+
+	fs := flag.NewFlagSet("tmp", flag.ContinueOnError)
+	_ = fs.Int("workers", 4, "")
+	if err := fs.Parse(nil); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString("workers = 100\n"); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	var cfg testConfig
+	err := Load(tmp.Name(), &cfg, WithFlagSet(fs), WithEnv(map[string]string{"LEVEL": "trace"}), WithValidation())
+
+	var multi MultiError
+	if !errors.As(err, &multi) {
+		t.Fatalf("got: %v, expected a MultiError", err)
+	}
+
+	var got []FieldError
+	for _, e := range multi {
+		fe := *e.(*FieldError)
+		fe.Err = nil
+		got = append(got, fe)
+	}
+
+	expected := []FieldError{
+		{Path: "name", Source: SourceNone},
+		{Path: "level", Source: SourceEnv, Key: "LEVEL", Value: "trace"},
+		{Path: "workers", Source: SourceFile, Key: "workers", Value: "100"},
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("(-want +got):\n%v", diff)
+	}
+}
+
+func TestLoad_FileErrorsWithCustomTags(t *testing.T) {
+	type testConfig struct {
+		Name string `cfg:"name" config:"toml=name"`
+		DB   struct {
+			Port int `cfg:"port" config:"toml=port"`
+		} `cfg:"database" config:"toml=database"`
+		Servers []struct {
+			Weight int `cfg:"weight" config:"toml=weight"`
+		} `cfg:"servers" config:"toml=servers"`
+	}
+
+	testcases := []struct {
+		name   string
+		files  map[string]string
+		opts   []Option
+		file   string
+		line   int
+		column int
+	}{
+		{
+			name:  "tag names",
+			files: map[string]string{"config.toml": "name = \"app\"\n\n[database]\nport = \"http\"\n"},
+			opts:  []Option{WithTagNames("cfg", "", "")},
+			file:  "config.toml", line: 4, column: 1,
+		},
+		{
+			name:  "tag names with strict decoding",
+			files: map[string]string{"config.toml": "name = \"app\"\n\n[database]\nport = \"http\"\n"},
+			opts:  []Option{WithTagNames("cfg", "", ""), WithStrict()},
+			file:  "config.toml", line: 4, column: 1,
+		},
+		{
+			name:  "combined tag in array of tables",
+			files: map[string]string{"config.toml": "[[servers]]\nweight = 1\n\n[[servers]]\nweight = \"a\"\n"},
+			opts:  []Option{WithCombinedTag("config")},
+			file:  "config.toml", line: 5, column: 1,
+		},
+		{
+			name: "combined tag in included file",
+			files: map[string]string{
+				"config.toml": "include = \"db.toml\"\nname = \"app\"\n",
+				"db.toml":     "[database]\nport = \"http\"\n",
+			},
+			opts: []Option{WithCombinedTag("config")},
+			file: "db.toml", line: 2, column: 1,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := writeIncludeTestFiles(t, tc.files)
+			defer os.RemoveAll(dir)
+
+			var cfg testConfig
+			err := New(tc.opts...).Load(filepath.Join(dir, "config.toml"), &cfg)
+
+			var fe *FileError
+			if !errors.As(err, &fe) {
+				t.Fatalf("expected a FileError, got: %v", err)
+			}
+
+			if got := filepath.Base(fe.File); got != tc.file || fe.Line != tc.line || fe.Column != tc.column {
+				t.Errorf("got: %v:%v:%v, expected: %v:%v:%v", got, fe.Line, fe.Column, tc.file, tc.line, tc.column)
+			}
+		})
+	}
+}
+
+func TestMultiError(t *testing.T) {
+	var errs MultiError
+	if errs.err() != nil {
+		t.Errorf("expected nil for no errors")
+	}
+
+	first := &FieldError{Path: "a", Err: errors.New("first")}
+	errs = errs.append(first).append(nil).append(MultiError{errors.New("second"), errors.New("third")})
+
+	if len(errs) != 3 {
+		t.Fatalf("got: %v errors, expected: %v", len(errs), 3)
+	}
+
+	if got, expected := errs.Error(), "3 errors: field 'a': first; second; third"; got != expected {
+		t.Errorf("got: %v, expected: %v", got, expected)
+	}
+
+	if got, expected := errs[:1].Error(), "field 'a': first"; got != expected {
+		t.Errorf("got: %v, expected: %v", got, expected)
+	}
+
+	var fe *FieldError
+	if !errors.As(errs.err(), &fe) || fe != first {
+		t.Errorf("expected errors.As to find the FieldError")
+	}
+
+	if !errors.Is(errs.err(), errs[2]) {
+		t.Errorf("expected errors.Is to match the errors in the list")
+	}
+}
+
+func TestLoad_FileErrors(t *testing.T) {
+	type testConfig struct {
+		Name string `toml:"name"`
+		DB   struct {
+			Port int `toml:"port"`
+		} `toml:"db"`
+		Servers []struct {
+			Weight int `toml:"weight"`
+		} `toml:"servers"`
+	}
+
+	testcases := []struct {
+		name     string
+		files    map[string]string
+		opts     []Option
+		file     string
+		line     int
+		column   int
+		expected string
+	}{
+		{
+			name:  "syntax error",
+			files: map[string]string{"config.toml": "name = \"app\"\nport x 8080\n"},
+			file:  "config.toml", line: 2, column: 6,
+			expected: "config.toml:2:6: was expecting token =, but got \"x\" instead\n" +
+				" 2 | port x 8080\n" +
+				"   |      ^",
+		},
+		{
+			name:  "type mismatch",
+			files: map[string]string{"config.toml": "name = \"app\"\n\n[db]\n\tport = \"http\"\n"},
+			file:  "config.toml", line: 4, column: 2,
+			expected: "config.toml:4:2: Can't convert http(string) to int\n" +
+				" 4 | \tport = \"http\"\n" +
+				"   | \t^",
+		},
+		{
+			name:  "type mismatch in array of tables",
+			files: map[string]string{"config.toml": "[[servers]]\nweight = 1\n\n[[servers]]\nweight = \"a\"\n"},
+			file:  "config.toml", line: 5, column: 1,
+		},
+		{
+			name: "type mismatch in included file",
+			files: map[string]string{
+				"config.toml": "include = \"db.toml\"\nname = \"app\"\n",
+				"db.toml":     "[db]\nport = \"http\"\n",
+			},
+			file: "db.toml", line: 2, column: 1,
+		},
+		{
+			name: "syntax error in included file",
+			files: map[string]string{
+				"config.toml": "include = \"db.toml\"\nname = \"app\"\n",
+				"db.toml":     "[db\nport = 5432\n",
+			},
+			file: "db.toml", line: 1, column: 2,
+		},
+		{
+			name: "type mismatch overridden by included file",
+			files: map[string]string{
+				"config.toml": "include = \"db.toml\"\n[db]\nport = \"http\"\n",
+				"db.toml":     "[db]\nport = 5432\n",
+			},
+			file: "config.toml", line: 3, column: 1,
+		},
+		{
+			name: "type mismatch in profile",
+			files: map[string]string{
+				"config.toml":      "name = \"app\"\n[db]\nport = 5432\n\n[profile.prod.db]\nport = \"http\"\n",
+				"config.prod.toml": "name = \"prod\"\n",
+			},
+			opts: []Option{WithEnv(map[string]string{"APP_PROFILE": "prod"}), WithProfile("APP_PROFILE", "", "")},
+			file: "config.toml", line: 6, column: 1,
+		},
+		{
+			name:  "type mismatch with strict decoding",
+			files: map[string]string{"config.toml": "name = \"app\"\n[db]\nport = \"http\"\n"},
+			opts:  []Option{WithStrict()},
+			file:  "config.toml", line: 3, column: 1,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := writeIncludeTestFiles(t, tc.files)
+			defer os.RemoveAll(dir)
+
+			var cfg testConfig
+			err := New(tc.opts...).Load(filepath.Join(dir, "config.toml"), &cfg)
+
+			var fe *FileError
+			if !errors.As(err, &fe) {
+				t.Fatalf("expected a FileError, got: %v", err)
+			}
+
+			if got := filepath.Base(fe.File); got != tc.file || fe.Line != tc.line || fe.Column != tc.column {
+				t.Errorf("got: %v:%v:%v, expected: %v:%v:%v", got, fe.Line, fe.Column, tc.file, tc.line, tc.column)
+			}
+
+			if tc.expected != "" && !strings.HasSuffix(err.Error(), tc.expected) {
+				t.Errorf("got: %q, expected suffix: %q", err.Error(), tc.expected)
+			}
+		})
+	}
+}
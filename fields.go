@@ -0,0 +1,253 @@
+package config
+
+import (
+	"encoding"
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml"
+)
+
+// defaultValue returns the default value of field, in a form accepted by toml.ValueStringRepresentation.
+func defaultValue(field reflect.StructField, v reflect.Value) (interface{}, error) {
+	val, err := rawDefaultValue(field, v)
+	if err != nil {
+		return nil, err
+	}
+
+	return tomlValue(val)
+}
+
+// rawDefaultValue returns the default value of field as marshalValue does. It is the flag default if field has a
+// "flag" tag, the "default" tag if it has one, and v otherwise. It fails if the "default" tag can't be parsed into the
+// type of field.
+func rawDefaultValue(field reflect.StructField, v reflect.Value) (interface{}, error) {
+	def, hasDefault := field.Tag.Lookup(defaultTag)
+	if tag := field.Tag.Get(flagTag); tag != "" && tag != "-" {
+		if fl := flag.Lookup(tag); fl != nil {
+			def, hasDefault = fl.DefValue, true
+		}
+	}
+
+	if hasDefault {
+		v = reflect.New(field.Type).Elem()
+		if err := setDefaultValue(v, field.Name, def); err != nil {
+			return nil, fmt.Errorf("invalid default %q: %v", def, err)
+		}
+	}
+
+	val, ok, err := marshalValue(v, marshalOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		// Nil pointer, use the zero value of the element instead.
+		val, _, err = marshalValue(reflect.New(derefType(field.Type)).Elem(), marshalOptions{})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return val, nil
+}
+
+// setDefaultValue will convert def to the type of v and set it
+func setDefaultValue(v reflect.Value, name string, def string) error {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(def)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(def))
+	}
+
+	return setValue(v, name, def)
+}
+
+// tomlValue converts the maps in a marshaled value into trees, so they are written as inline tables.
+func tomlValue(val interface{}) (interface{}, error) {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		return toml.TreeFromMap(v)
+	case []interface{}:
+		s := make([]interface{}, 0, len(v))
+		for _, e := range v {
+			se, err := tomlValue(e)
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, se)
+		}
+		return s, nil
+	}
+
+	return val, nil
+}
+
+// keyPath joins the keys of path, quoting them if needed.
+func keyPath(path []string) string {
+	keys := make([]string, 0, len(path))
+	for _, k := range path {
+		keys = append(keys, quoteTomlKey(k))
+	}
+	return strings.Join(keys, ".")
+}
+
+// quoteTomlKey quotes k if it is not a valid bare key.
+func quoteTomlKey(k string) string {
+	for _, r := range k {
+		if !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return fmt.Sprintf("%q", k)
+		}
+	}
+
+	if k == "" {
+		return `""`
+	}
+
+	return k
+}
+
+// isTableType will check if t is written as a table or an array of tables
+func isTableType(t reflect.Type) bool {
+	t = derefType(t)
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		return isStructType(derefType(t.Elem()))
+	}
+
+	return isStructType(t)
+}
+
+// isStructType will check if t is a struct that is not encoded as a single value
+func isStructType(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct || t == reflect.TypeOf(time.Time{}) {
+		return false
+	}
+
+	return !reflect.PtrTo(t).Implements(reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem())
+}
+
+// derefType returns the element type of t if t is a pointer
+func derefType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+
+	return t
+}
+
+// derefValue returns the element of v if v is a pointer, or a zero element if v is a nil pointer
+func derefValue(v reflect.Value) reflect.Value {
+	if v.Kind() != reflect.Ptr {
+		return v
+	}
+
+	if v.IsNil() {
+		return reflect.New(v.Type().Elem()).Elem()
+	}
+
+	return v.Elem()
+}
+
+// fileField is a struct field that is read from the config file.
+type fileField struct {
+	field reflect.StructField
+	value reflect.Value
+	key   string
+}
+
+// fileFields returns the fields of v that are read from the config file. Embedded structs without a toml name are
+// flattened into the parent, but not embedded pointers, which go-toml decodes from their own table.
+func fileFields(v reflect.Value) []fileField {
+	var fields []fileField
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		key, _ := parseTomlTag(field)
+		if key == "-" {
+			continue
+		}
+
+		fv := v.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get(tomlTag) == "" {
+			if elem := derefValue(fv); elem.Kind() == reflect.Struct {
+				fields = append(fields, fileFields(elem)...)
+				continue
+			}
+		}
+
+		fields = append(fields, fileField{field: field, value: fv, key: key})
+	}
+
+	return fields
+}
+
+// setting is a single configuration value found by walking a config struct.
+type setting struct {
+	field reflect.StructField
+	value reflect.Value
+
+	// tomlPath is the path of the setting in the config file, or nil if it is not read from the file. Elements of
+	// arrays of tables are denoted by a "[]" key.
+	tomlPath []string
+}
+
+// settings returns the settings of v. Nested structs are walked like bindSources does, arrays of tables through a zero
+// element, and embedded structs (not pointers) without a toml name are flattened into the parent. If inFile is false,
+// none of the settings of v are read from the config file.
+func settings(v reflect.Value, path []string, inFile bool) []setting {
+	var result []setting
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		fv := v.Field(i)
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get(tomlTag) == "" {
+			if elem := derefValue(fv); elem.Kind() == reflect.Struct {
+				result = append(result, settings(elem, path, inFile)...)
+				continue
+			}
+		}
+
+		key, _ := parseTomlTag(field)
+		fieldInFile := inFile && key != "-"
+		fieldPath := append(append([]string{}, path...), key)
+
+		switch ft := derefType(field.Type); {
+		case isStructType(ft):
+			result = append(result, settings(derefValue(fv), fieldPath, fieldInFile)...)
+			continue
+		case isTableType(ft):
+			elem := reflect.New(derefType(ft.Elem())).Elem()
+			result = append(result, settings(elem, append(fieldPath, "[]"), fieldInFile)...)
+			continue
+		}
+
+		s := setting{field: field, value: fv}
+		if fieldInFile {
+			s.tomlPath = fieldPath
+		}
+		result = append(result, s)
+	}
+
+	return result
+}
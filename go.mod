@@ -3,9 +3,9 @@ module github.com/peak/go-config
 go 1.16
 
 require (
-	github.com/fatih/structs v1.0.0
 	github.com/fsnotify/fsnotify v1.4.7
 	github.com/google/go-cmp v0.5.6
 	github.com/pelletier/go-toml v1.9.4
 	golang.org/x/sys v0.0.0-20210915083310-ed5796bab164 // indirect
+	gopkg.in/yaml.v3 v3.0.1
 )
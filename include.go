@@ -0,0 +1,154 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+// includeKeys are the keys of the include directive. Its value is a path or a list of paths, which can be glob patterns.
+var includeKeys = []string{"include", "@include"}
+
+// IncludedFiles returns filepath and the files it includes, recursively, in the order they are merged by LoadTree with
+// opts, so that the keys of the loaded tree can be traced back to their file. The file of the active profile, if
+// profiles are enabled with WithProfile, is the last one.
+func IncludedFiles(filepath string, opts ...Option) ([]string, error) {
+	src, err := newOptions(opts).sources()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := loadTree(filepath, src); err != nil {
+		return nil, err
+	}
+
+	return src.files, nil
+}
+
+// loadFileTree loads filepath into a TOML tree, resolving its include directives. Included files are merged in order,
+// and filepath is merged over them. Relative paths are resolved relative to the including file.
+//
+// stack is the list of files that are being included, used to detect cycles. The loaded files are added to src.files.
+// Syntax errors are returned as FileErrors.
+func loadFileTree(filepath string, stack []string, src *sources) (*toml.Tree, error) {
+	abspath, err := absPath(filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range stack {
+		if p == abspath {
+			return nil, fmt.Errorf("include cycle: %v", strings.Join(append(stack, abspath), " -> "))
+		}
+	}
+	stack = append(stack, abspath)
+
+	tree, err := toml.LoadFile(filepath)
+	if err != nil {
+		return nil, newFileError(filepath, err)
+	}
+
+	patterns, err := includePatterns(tree)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", filepath, err)
+	}
+
+	if len(patterns) == 0 {
+		src.files = append(src.files, filepath)
+		return tree, nil
+	}
+
+	base, err := toml.TreeFromMap(map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pattern := range patterns {
+		files, err := includeFiles(abspath, pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", filepath, err)
+		}
+
+		for _, file := range files {
+			included, err := loadFileTree(file, stack, src)
+			if err != nil {
+				if isFileError(err) {
+					return nil, err
+				}
+				return nil, fmt.Errorf("include %v: %v", file, err)
+			}
+
+			mergeTrees(base, included)
+		}
+	}
+
+	mergeTrees(base, tree)
+	src.files = append(src.files, filepath)
+	return base, nil
+}
+
+// includePatterns removes the include directives from tree and returns their paths.
+func includePatterns(tree *toml.Tree) ([]string, error) {
+	var patterns []string
+	for _, key := range includeKeys {
+		if !tree.Has(key) {
+			continue
+		}
+
+		switch v := tree.GetPath([]string{key}).(type) {
+		case string:
+			patterns = append(patterns, v)
+		case []interface{}:
+			for _, p := range v {
+				s, ok := p.(string)
+				if !ok {
+					return nil, fmt.Errorf("%v should be a list of paths, got %v", key, p)
+				}
+				patterns = append(patterns, s)
+			}
+		default:
+			return nil, fmt.Errorf("%v should be a path or a list of paths, got %v", key, v)
+		}
+
+		if err := tree.DeletePath([]string{key}); err != nil {
+			return nil, err
+		}
+	}
+
+	return patterns, nil
+}
+
+// includeFiles returns the files matching pattern, relative to the directory of the including file, in lexical order.
+// Patterns without glob characters should match an existing file.
+func includeFiles(including string, pattern string) ([]string, error) {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(filepath.Dir(including), pattern)
+	}
+
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 && !strings.ContainsAny(pattern, `*?[`) {
+		return nil, fmt.Errorf("included file %v does not exist", pattern)
+	}
+
+	return files, nil
+}
+
+// absPath returns the absolute path of path, with symbolic links resolved if possible.
+func absPath(path string) (string, error) {
+	abspath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	if resolved, err := filepath.EvalSymlinks(abspath); err == nil {
+		return resolved, nil
+	}
+
+	return abspath, nil
+}
@@ -0,0 +1,176 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type includeTestConfig struct {
+	Name string `toml:"name"`
+	Log  struct {
+		Level  string `toml:"level"`
+		Format string `toml:"format"`
+	} `toml:"log"`
+	Tracing struct {
+		Endpoint string `toml:"endpoint"`
+	} `toml:"tracing"`
+}
+
+func writeIncludeTestFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir, _ := ioutil.TempDir("", "")
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("write config file failed: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestLoad_Include(t *testing.T) {
+	dir := writeIncludeTestFiles(t, map[string]string{
+		"common/logging.toml": `
+[log]
+level = "info"
+format = "json"
+`,
+		"common/tracing.toml": `
+[tracing]
+endpoint = "localhost:4317"
+`,
+		"app/config.toml": `
+include = ["../common/logging.toml"]
+"@include" = "../common/tracing*.toml"
+
+name = "app"
+
+[log]
+level = "debug"
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	var cfg includeTestConfig
+	if err := Load(filepath.Join(dir, "app", "config.toml"), &cfg); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if cfg.Name != "app" {
+		t.Errorf("got: %v, expected: %v", cfg.Name, "app")
+	}
+
+	// overridden by the including file
+	if cfg.Log.Level != "debug" {
+		t.Errorf("got: %v, expected: %v", cfg.Log.Level, "debug")
+	}
+
+	if cfg.Log.Format != "json" {
+		t.Errorf("got: %v, expected: %v", cfg.Log.Format, "json")
+	}
+
+	if cfg.Tracing.Endpoint != "localhost:4317" {
+		t.Errorf("got: %v, expected: %v", cfg.Tracing.Endpoint, "localhost:4317")
+	}
+}
+
+func TestLoad_IncludeGlobOrder(t *testing.T) {
+	dir := writeIncludeTestFiles(t, map[string]string{
+		"conf/10-name.toml": `name = "first"`,
+		"conf/20-name.toml": `name = "second"`,
+		"config.toml":       `include = "conf/*.toml"`,
+	})
+	defer os.RemoveAll(dir)
+
+	var cfg includeTestConfig
+	if err := Load(filepath.Join(dir, "config.toml"), &cfg); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if cfg.Name != "second" {
+		t.Errorf("got: %v, expected: %v", cfg.Name, "second")
+	}
+}
+
+func TestIncludedFiles(t *testing.T) {
+	dir := writeIncludeTestFiles(t, map[string]string{
+		"conf/10-name.toml": `include = "../common.toml"`,
+		"conf/20-name.toml": `name = "second"`,
+		"common.toml":       `name = "common"`,
+		"config.toml":       `include = "conf/*.toml"`,
+		"config.prod.toml":  `name = "prod"`,
+	})
+	defer os.RemoveAll(dir)
+
+	testcases := []struct {
+		name     string
+		opts     []Option
+		expected []string
+	}{
+		{
+			name:     "without profiles",
+			expected: []string{"common.toml", "conf/10-name.toml", "conf/20-name.toml", "config.toml"},
+		},
+		{
+			name:     "with profile",
+			opts:     []Option{WithEnv(map[string]string{"APP_PROFILE": "prod"}), WithProfile("APP_PROFILE", "", "")},
+			expected: []string{"common.toml", "conf/10-name.toml", "conf/20-name.toml", "config.toml", "config.prod.toml"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			files, err := IncludedFiles(filepath.Join(dir, "config.toml"), tc.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			var got []string
+			for _, f := range files {
+				rel, _ := filepath.Rel(dir, f)
+				got = append(got, filepath.ToSlash(rel))
+			}
+
+			if strings.Join(got, ",") != strings.Join(tc.expected, ",") {
+				t.Errorf("got: %v, expected: %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestLoad_ErrorIfIncludeCycle(t *testing.T) {
+	dir := writeIncludeTestFiles(t, map[string]string{
+		"a.toml": `include = ["b.toml"]`,
+		"b.toml": `include = ["a.toml"]`,
+	})
+	defer os.RemoveAll(dir)
+
+	var cfg includeTestConfig
+	err := Load(filepath.Join(dir, "a.toml"), &cfg)
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+
+	if !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("expected include cycle error, got: %v", err)
+	}
+}
+
+func TestLoad_ErrorIfIncludeNotExist(t *testing.T) {
+	dir := writeIncludeTestFiles(t, map[string]string{
+		"config.toml": `include = ["missing.toml"]`,
+	})
+	defer os.RemoveAll(dir)
+
+	var cfg includeTestConfig
+	if err := Load(filepath.Join(dir, "config.toml"), &cfg); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+}
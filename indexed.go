@@ -0,0 +1,533 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+// bindSlice will bind environment variables and CLI flags to the elements of the slice of structs field in dst. The
+// "env" and "flag" struct-tags of the element fields are prefixed with the ones of the slice and the index, e.g.
+// APP_SERVERS_0_HOST and -servers.0.host. Elements are appended while there are overrides beyond the end of the slice,
+// and overrides of indexes after a gap are errors.
+func bindSlice(sliceVal reflect.Value, fp *fieldPlan, tree *toml.Tree, fieldPath, envKey, flagName string, src *sources) error {
+	if envKey == "" && flagName == "" {
+		return nil
+	}
+
+	// Elements loaded from the file, to check which keys they have
+	var elemTrees []*toml.Tree
+	if table := subtree(tree, fieldPath); table != nil {
+		if key, ok := lookupKey(table, fp.tomlName, fp.name); ok {
+			elemTrees, _ = table.GetPath([]string{key}).([]*toml.Tree)
+		}
+	}
+
+	path := src.fieldPath(fp.nestedPath(fieldPath))
+
+	var errs MultiError
+	for i := 0; ; i++ {
+		elemSrc := src.elem(envKey, flagName, strconv.Itoa(i))
+		elemSrc.path = fmt.Sprintf("%s[%d]", path, i)
+
+		appended := false
+		if i >= sliceVal.Len() {
+			if !hasIndexedValue(sliceVal.Type().Elem(), elemSrc) {
+				return errs.append(gapErrors(sliceVal.Type().Elem(), i, path, envKey, flagName, src)).err()
+			}
+
+			sliceVal.Set(reflect.Append(sliceVal, reflect.Zero(sliceVal.Type().Elem())))
+			appended = true
+		}
+
+		elem := sliceVal.Index(i)
+		if elem.Kind() == reflect.Ptr {
+			if elem.IsNil() {
+				elem.Set(reflect.New(elem.Type().Elem()))
+			}
+			elem = elem.Elem()
+		}
+
+		if appended {
+			if err := applyDefaults(elem); err != nil {
+				errs = append(errs, &FieldError{Path: elemSrc.path, Err: err})
+				continue
+			}
+		}
+
+		var elemTree *toml.Tree
+		if i < len(elemTrees) {
+			elemTree = elemTrees[i]
+		}
+
+		errs = errs.append(bindSources(elem.Addr().Interface(), elemTree, "", elemSrc))
+	}
+}
+
+// bindMap will bind environment variables and CLI flags to the values of the map of structs field in dst. The "env" and
+// "flag" struct-tags of the value fields are prefixed with the ones of the map and the key, e.g.
+// APP_DATABASES_PRIMARY_HOST and -databases.primary.host. Values are added for the keys of overrides that are not in
+// the map.
+func bindMap(mapVal reflect.Value, fp *fieldPlan, tree *toml.Tree, fieldPath, envKey, flagName string, src *sources) error {
+	if envKey == "" && flagName == "" {
+		return nil
+	}
+
+	elemType := mapVal.Type().Elem()
+
+	// Values loaded from the file, to check which keys they have
+	var table *toml.Tree
+	if parent := subtree(tree, fieldPath); parent != nil {
+		if key, ok := lookupKey(parent, fp.tomlName, fp.name); ok {
+			table, _ = parent.GetPath([]string{key}).(*toml.Tree)
+		}
+	}
+
+	keys := make(map[string]bool)
+	envNames := make(map[string]bool)
+	for _, k := range mapVal.MapKeys() {
+		keys[k.String()] = true
+		envNames[envKeyName(k.String())] = true
+	}
+	for _, k := range overrideKeys(elemType, envKey, flagName, src) {
+		if !keys[k] && !envNames[envKeyName(k)] {
+			keys[k] = false
+		}
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	path := src.fieldPath(fp.nestedPath(fieldPath))
+
+	var errs MultiError
+	for _, key := range sorted {
+		elemSrc := src.elem(envKey, flagName, key)
+		elemSrc.path = fmt.Sprintf("%s.%s", path, key)
+		exists := keys[key]
+		if !exists && !hasIndexedValue(elemType, elemSrc) {
+			continue
+		}
+
+		mapKey := reflect.ValueOf(key).Convert(mapVal.Type().Key())
+
+		// Map values are not addressable, bind a copy
+		elem := reflect.New(derefType(elemType)).Elem()
+		if exists {
+			if v := mapVal.MapIndex(mapKey); elemType.Kind() != reflect.Ptr {
+				elem.Set(v)
+			} else if !v.IsNil() {
+				elem = v.Elem()
+			}
+		} else if err := applyDefaults(elem); err != nil {
+			errs = append(errs, &FieldError{Path: elemSrc.path, Err: err})
+			continue
+		}
+
+		var elemTree *toml.Tree
+		if table != nil {
+			elemTree, _ = table.GetPath([]string{key}).(*toml.Tree)
+		}
+
+		if err := bindSources(elem.Addr().Interface(), elemTree, "", elemSrc); err != nil {
+			errs = errs.append(err)
+			continue
+		}
+
+		if mapVal.IsNil() {
+			mapVal.Set(reflect.MakeMap(mapVal.Type()))
+		}
+
+		if elemType.Kind() == reflect.Ptr {
+			mapVal.SetMapIndex(mapKey, elem.Addr())
+		} else {
+			mapVal.SetMapIndex(mapKey, elem)
+		}
+	}
+
+	return errs.err()
+}
+
+// overrideKeys returns the map keys that have overrides of the fields of typ, from the names of the environment
+// variables prefixed with envKey, if they can be listed, and the given flags prefixed with flagName. Keys found in
+// environment variable names are lowercased.
+func overrideKeys(typ reflect.Type, envKey, flagName string, src *sources) []string {
+	var keys []string
+
+	if envKey != "" && src.envNames != nil {
+		leaves, containers := envSuffixes(typ, src.tags)
+		prefix := envKey + "_"
+		for _, name := range src.envNames() {
+			if !strings.HasPrefix(name, prefix) {
+				continue
+			}
+
+			if key, ok := envMapKey(strings.TrimPrefix(name, prefix), leaves, containers); ok {
+				keys = append(keys, strings.ToLower(key))
+			}
+		}
+	}
+
+	if flagName != "" {
+		prefix := flagName + "."
+		for name := range src.setFlags {
+			if !strings.HasPrefix(name, prefix) {
+				continue
+			}
+
+			rest := strings.TrimPrefix(name, prefix)
+			if i := strings.Index(rest, "."); i > 0 {
+				keys = append(keys, rest[:i])
+			}
+		}
+	}
+
+	return keys
+}
+
+// envSuffixes returns the "env" struct-tags of the fields of typ, for fields with values (leaves) and for slices and maps
+// of structs (containers). Nested structs without tags are flattened.
+func envSuffixes(typ reflect.Type, tags tagNames) (leaves, containers []string) {
+	plan := planFor(derefType(typ), tags)
+	for i := range plan.fields {
+		fp := &plan.fields[i]
+		switch {
+		case fp.envKey == "" && fp.nested:
+			l, c := envSuffixes(fp.typ, tags)
+			leaves, containers = append(leaves, l...), append(containers, c...)
+		case fp.envKey == "":
+		case fp.structSlice || fp.structMap:
+			containers = append(containers, fp.envKey)
+		default:
+			leaves = append(leaves, fp.envKey)
+		}
+	}
+
+	return leaves, containers
+}
+
+// envMapKey returns the map key in name, which is the rest of an environment variable name after the prefix of the map.
+// The shortest key followed by one of the leaves at the end, or one of the containers, is returned.
+func envMapKey(name string, leaves, containers []string) (string, bool) {
+	key := ""
+	for _, leaf := range leaves {
+		if k := strings.TrimSuffix(name, "_"+leaf); k != name && k != "" && (key == "" || len(k) < len(key)) {
+			key = k
+		}
+	}
+
+	for _, container := range containers {
+		if i := strings.Index(name, "_"+container+"_"); i > 0 && (key == "" || i < len(key)) {
+			key = name[:i]
+		}
+	}
+
+	return key, key != ""
+}
+
+// envKeyName converts a map key to the form used in environment variable names, e.g. PRIMARY for primary.
+func envKeyName(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z' || r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, key)
+}
+
+// applyDefaults will set the fields of v that have a "default" struct-tag to its value, as the decoder does for fields
+// that are not in the file.
+func applyDefaults(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		fv := v.Field(i)
+		if def, ok := field.Tag.Lookup(defaultTag); ok {
+			if err := setDefaultValue(fv, field.Name, def); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if fv.Kind() == reflect.Struct && isStructType(fv.Type()) {
+			if err := applyDefaults(fv); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// gapErrors returns an error for each index after the missing one that has overrides of the fields of typ, which are
+// ignored since elements are only appended at the end of the slice.
+func gapErrors(typ reflect.Type, missing int, path, envKey, flagName string, src *sources) error {
+	seen := make(map[int]bool)
+	var indexes []int
+	for _, k := range overrideKeys(typ, envKey, flagName, src) {
+		i, err := strconv.Atoi(k)
+		if err != nil || i <= missing || seen[i] {
+			continue
+		}
+
+		seen[i] = true
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	var errs MultiError
+	for _, i := range indexes {
+		source, key, ok := indexedValue(typ, src.elem(envKey, flagName, strconv.Itoa(i)))
+		if !ok {
+			continue
+		}
+
+		errs = append(errs, &FieldError{
+			Path:   fmt.Sprintf("%s[%d]", path, i),
+			Source: source,
+			Key:    key,
+			Err:    fmt.Errorf("no element at index %d, overrides must not skip indexes", missing),
+		})
+	}
+
+	return errs.err()
+}
+
+// hasIndexedValue will check if any "env" or "flag" struct-tag of the fields of typ, prefixed as in src, has a value
+func hasIndexedValue(typ reflect.Type, src *sources) bool {
+	_, _, ok := indexedValue(typ, src)
+	return ok
+}
+
+// indexedValue returns the source and the name of the first "env" or "flag" struct-tag of the fields of typ, prefixed as
+// in src, that has a value.
+func indexedValue(typ reflect.Type, src *sources) (Source, string, bool) {
+	plan := planFor(derefType(typ), src.tags)
+	for i := range plan.fields {
+		fp := &plan.fields[i]
+		envKey, flagName := src.indexedNames(fp.envKey, fp.flagName)
+
+		if fp.structSlice {
+			if envKey == "" && flagName == "" {
+				continue
+			}
+
+			if source, key, ok := indexedValue(fp.typ.Elem(), src.elem(envKey, flagName, "0")); ok {
+				return source, key, true
+			}
+			continue
+		}
+
+		if fp.structMap {
+			for _, k := range overrideKeys(fp.typ.Elem(), envKey, flagName, src) {
+				if source, key, ok := indexedValue(fp.typ.Elem(), src.elem(envKey, flagName, k)); ok {
+					return source, key, true
+				}
+			}
+			continue
+		}
+
+		if envKey == "" && flagName == "" {
+			if fp.nested {
+				if source, key, ok := indexedValue(fp.typ, src); ok {
+					return source, key, true
+				}
+			}
+			continue
+		}
+
+		if envKey != "" {
+			if _, ok := src.lookupEnv(envKey); ok {
+				return SourceEnv, envKey, true
+			}
+		}
+
+		if flagName != "" && src.isFlagSet(flagName) {
+			return SourceFlag, flagName, true
+		}
+	}
+
+	return SourceNone, "", false
+}
+
+// DefineIndexedFlags defines the flags in args that override the elements of slices and maps of structs in dst, such as
+// -servers.1.port=9000 for the Port field, tagged `flag:"port"`, of the elements of a field tagged `flag:"servers"`, or
+// -databases.primary.host for a map.
+// The flag package only parses defined flags, so it should be called before flag.Parse. The flags are defined in
+// flag.CommandLine, or in the flag set given with WithFlagSet.
+func DefineIndexedFlags(dst interface{}, args []string, opts ...Option) {
+	New(opts...).DefineIndexedFlags(dst, args)
+}
+
+// DefineIndexedFlags defines the flags in args that override the elements of slices and maps of structs in dst. See the
+// package-level DefineIndexedFlags.
+func (l *Loader) DefineIndexedFlags(dst interface{}, args []string) {
+	fs := l.opts.flags
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+
+	for _, arg := range args {
+		if arg == "--" {
+			return
+		}
+
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+
+		name := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if i := strings.Index(name, "="); i >= 0 {
+			name = name[:i]
+		}
+
+		if fs.Lookup(name) != nil {
+			continue
+		}
+
+		typ, ok := indexedFlagType(l.opts.tags, reflect.TypeOf(dst), name, false)
+		if !ok {
+			continue
+		}
+
+		if derefType(typ).Kind() == reflect.Bool {
+			fs.Bool(name, false, "")
+		} else {
+			fs.String(name, "", "")
+		}
+	}
+}
+
+// indexedFlagType returns the type of the field of typ that the indexed flag name binds to. indexed is true for the
+// fields of the elements of a slice.
+func indexedFlagType(tags tagNames, typ reflect.Type, name string, indexed bool) (reflect.Type, bool) {
+	typ = derefType(typ)
+	if !isStructType(typ) {
+		return nil, false
+	}
+
+	plan := planFor(typ, tags)
+	for i := range plan.fields {
+		fp := &plan.fields[i]
+		if fp.flagName == "" {
+			if !fp.structSlice && !fp.structMap {
+				if t, ok := indexedFlagType(tags, fp.typ, name, indexed); ok {
+					return t, true
+				}
+			}
+			continue
+		}
+
+		if fp.structSlice {
+			if !strings.HasPrefix(name, fp.flagName+".") {
+				continue
+			}
+
+			rest := strings.TrimPrefix(name, fp.flagName+".")
+			dot := strings.Index(rest, ".")
+			if dot < 0 {
+				continue
+			}
+
+			if _, err := strconv.ParseUint(rest[:dot], 10, 0); err != nil {
+				continue
+			}
+
+			if t, ok := indexedFlagType(tags, fp.typ.Elem(), rest[dot+1:], true); ok {
+				return t, true
+			}
+			continue
+		}
+
+		if fp.structMap {
+			rest := strings.TrimPrefix(name, fp.flagName+".")
+			dot := strings.Index(rest, ".")
+			if rest == name || dot <= 0 {
+				continue
+			}
+
+			if t, ok := indexedFlagType(tags, fp.typ.Elem(), rest[dot+1:], true); ok {
+				return t, true
+			}
+			continue
+		}
+
+		if indexed && name == fp.flagName {
+			return fp.typ, true
+		}
+	}
+
+	return nil, false
+}
+
+// elem returns the sources of the element at index, which is a slice index or a map key, of a slice or a map with the
+// (already prefixed) envKey and flagName.
+func (src *sources) elem(envKey, flagName string, index string) *sources {
+	elemSrc := *src
+	elemSrc.indexed = true
+	elemSrc.envPrefix, elemSrc.flagPrefix = "", ""
+	if envKey != "" {
+		elemSrc.envPrefix = fmt.Sprintf("%s_%s_", envKey, envKeyName(index))
+	}
+	if flagName != "" {
+		elemSrc.flagPrefix = fmt.Sprintf("%s.%s.", flagName, index)
+	}
+	return &elemSrc
+}
+
+// indexedNames prefixes the "env" and "flag" struct-tags of a field of an element. Struct-tags without a prefix are
+// ignored.
+func (src *sources) indexedNames(envKey, flagName string) (string, string) {
+	if envKey != "" {
+		if src.envPrefix == "" {
+			envKey = ""
+		} else {
+			envKey = src.envPrefix + envKey
+		}
+	}
+
+	if flagName != "" {
+		if src.flagPrefix == "" {
+			flagName = ""
+		} else {
+			flagName = src.flagPrefix + flagName
+		}
+	}
+
+	return envKey, flagName
+}
+
+// isStructSliceType will check if typ is a slice of structs or pointers to structs
+func isStructSliceType(typ reflect.Type) bool {
+	return typ != nil && typ.Kind() == reflect.Slice && isStructType(derefType(typ.Elem()))
+}
+
+// isStructMapType will check if typ is a map with string keys of structs or pointers to structs
+func isStructMapType(typ reflect.Type) bool {
+	return typ != nil && typ.Kind() == reflect.Map && typ.Key().Kind() == reflect.String && isStructType(derefType(typ.Elem()))
+}
+
+// subtree returns the table at the dotted path in tree, or tree itself if path is empty.
+func subtree(tree *toml.Tree, path string) *toml.Tree {
+	if tree == nil || path == "" {
+		return tree
+	}
+
+	table, _ := tree.Get(strings.ToLower(path)).(*toml.Tree)
+	return table
+}
@@ -0,0 +1,235 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type indexedTestServer struct {
+	Host string `toml:"host" env:"HOST" flag:"host"`
+	Port int    `toml:"port" env:"PORT" flag:"port"`
+	TLS  bool   `toml:"tls" flag:"tls"`
+}
+
+type indexedTestConfig struct {
+	Name    string               `toml:"name"`
+	Servers []indexedTestServer  `toml:"servers" env:"APP_SERVERS" flag:"servers"`
+	Backups []*indexedTestServer `toml:"backups" env:"APP_BACKUPS"`
+}
+
+const indexedTestFile = `
+name = "example"
+
+[[servers]]
+host = "10.0.0.1"
+port = 80
+
+[[servers]]
+host = "10.0.0.2"
+port = 80
+`
+
+func TestLoad_IndexedOverrides(t *testing.T) {
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(indexedTestFile); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	args := []string{"-servers.1.port=9000", "-servers.2.host", "10.0.0.3", "-servers.2.tls", "-other"}
+
+	fs := flag.NewFlagSet("tmp", flag.ContinueOnError)
+	_ = fs.Bool("other", false, "")
+
+	var cfg indexedTestConfig
+	DefineIndexedFlags(&cfg, args, WithFlagSet(fs))
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	env := map[string]string{
+		"APP_SERVERS_0_HOST": "db.example.com",
+		"APP_SERVERS_1_PORT": "8080", // the flag has higher precedence
+		"APP_BACKUPS_0_HOST": "backup.example.com",
+	}
+
+	if err := Load(tmp.Name(), &cfg, WithFlagSet(fs), WithEnv(env)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := indexedTestConfig{
+		Name: "example",
+		Servers: []indexedTestServer{
+			{Host: "db.example.com", Port: 80},
+			{Host: "10.0.0.2", Port: 9000},
+			{Host: "10.0.0.3", TLS: true},
+		},
+		Backups: []*indexedTestServer{
+			{Host: "backup.example.com"},
+		},
+	}
+	if diff := cmp.Diff(expected, cfg); diff != "" {
+		t.Errorf("(-want +got):\n%v", diff)
+	}
+}
+
+func TestLoad_ErrorIfIndexedOverridesAfterGap(t *testing.T) {
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString("[[servers]]\nhost = \"10.0.0.1\"\n"); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	args := []string{"-servers.4.port=9000"}
+
+	fs := flag.NewFlagSet("tmp", flag.ContinueOnError)
+
+	var cfg indexedTestConfig
+	DefineIndexedFlags(&cfg, args, WithFlagSet(fs))
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	env := map[string]string{
+		"APP_SERVERS_1_PORT": "8080",
+		"APP_SERVERS_3_HOST": "10.0.0.4", // index 2 is missing
+	}
+
+	err := Load(tmp.Name(), &cfg, WithFlagSet(fs), WithEnv(env))
+
+	var multi MultiError
+	if !errors.As(err, &multi) {
+		t.Fatalf("got: %v, expected a MultiError", err)
+	}
+
+	if msg := multi[0].Error(); !strings.Contains(msg, "no element at index 2") {
+		t.Errorf("got: %v, expected the missing index", msg)
+	}
+
+	var got []FieldError
+	for _, e := range multi {
+		fe := *e.(*FieldError)
+		fe.Err = nil
+		got = append(got, fe)
+	}
+
+	expected := []FieldError{
+		{Path: "servers[3]", Source: SourceEnv, Key: "APP_SERVERS_3_HOST"},
+		{Path: "servers[4]", Source: SourceFlag, Key: "servers.4.port"},
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("(-want +got):\n%v", diff)
+	}
+}
+
+func TestLoad_ErrorIfIndexedTypeMismatch(t *testing.T) {
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	var cfg indexedTestConfig
+	env := map[string]string{"APP_SERVERS_0_PORT": "http"}
+	if err := Load(tmp.Name(), &cfg, WithFlagSet(flag.NewFlagSet("tmp", flag.ContinueOnError)), WithEnv(env)); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+}
+
+type keyedTestDatabase struct {
+	Host     string `toml:"host" env:"HOST" flag:"host" required:"true"`
+	Port     int    `toml:"port" env:"PORT" flag:"port" default:"5432"`
+	Password string `toml:"password" env:"PASSWORD" secret:"true"`
+}
+
+type keyedTestConfig struct {
+	Databases map[string]keyedTestDatabase  `toml:"databases" env:"APP_DATABASES" flag:"databases"`
+	Caches    map[string]*keyedTestDatabase `toml:"caches" env:"APP_CACHES"`
+}
+
+const keyedTestFile = `
+[databases.primary]
+host = "10.0.0.1"
+
+[databases.replica]
+host = "10.0.0.2"
+port = 6432
+`
+
+func TestLoad_KeyedOverrides(t *testing.T) {
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(keyedTestFile); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	args := []string{"-databases.replica.port=7432", "-databases.analytics.host", "10.0.0.4"}
+
+	fs := flag.NewFlagSet("tmp", flag.ContinueOnError)
+
+	var cfg keyedTestConfig
+	DefineIndexedFlags(&cfg, args, WithFlagSet(fs))
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	env := map[string]string{
+		"APP_DATABASES_PRIMARY_HOST":     "db.example.com",
+		"APP_DATABASES_PRIMARY_PASSWORD": "12345",
+		"APP_DATABASES_ARCHIVE_HOST":     "10.0.0.3",
+		"APP_CACHES_SESSIONS_HOST":       "cache.example.com",
+	}
+
+	if err := Load(tmp.Name(), &cfg, WithFlagSet(fs), WithEnv(env)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := keyedTestConfig{
+		Databases: map[string]keyedTestDatabase{
+			"primary":   {Host: "db.example.com", Port: 5432, Password: "12345"},
+			"replica":   {Host: "10.0.0.2", Port: 7432},
+			"archive":   {Host: "10.0.0.3", Port: 5432},
+			"analytics": {Host: "10.0.0.4", Port: 5432},
+		},
+		Caches: map[string]*keyedTestDatabase{
+			"sessions": {Host: "cache.example.com", Port: 5432},
+		},
+	}
+	if diff := cmp.Diff(expected, cfg); diff != "" {
+		t.Errorf("(-want +got):\n%v", diff)
+	}
+
+	b, err := Marshal(&cfg, Redact())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if strings.Contains(string(b), "12345") || !strings.Contains(string(b), RedactedValue) {
+		t.Errorf("expected secret map values to be redacted, got:\n%s", b)
+	}
+}
+
+func TestLoad_ErrorIfMapValueNotValid(t *testing.T) {
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString("[databases.primary]\nport = 5432\n"); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	var cfg keyedTestConfig
+	err := Load(tmp.Name(), &cfg, WithFlagSet(flag.NewFlagSet("tmp", flag.ContinueOnError)), WithEnv(nil), WithValidation())
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+
+	if !strings.Contains(err.Error(), "databases.primary.host") {
+		t.Errorf("got: %v, expected error about databases.primary.host", err)
+	}
+}
@@ -0,0 +1,236 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/pelletier/go-toml"
+)
+
+// Loader loads configuration files into structs, with the sources and behavior set by its options. A Loader can be
+// reused, and is safe for concurrent use if its options are.
+type Loader struct {
+	opts options
+}
+
+// New returns a Loader configured by opts. Without options, it behaves like the package-level Load functions.
+func New(opts ...Option) *Loader {
+	return &Loader{opts: newOptions(opts)}
+}
+
+// Load loads filepath into dst. It also handles "env" and "flag" binding.
+func (l *Loader) Load(filepath string, dst interface{}) error {
+	src, err := l.opts.sources()
+	if err != nil {
+		return err
+	}
+
+	tree, err := loadTree(filepath, src)
+	if err != nil {
+		return err
+	}
+
+	return l.load(tree, dst, src)
+}
+
+// LoadDir loads all *.toml files in dir into dst, deep-merging them in lexical order so later files override earlier
+// ones. It also handles "env" and "flag" binding.
+func (l *Loader) LoadDir(dir string, dst interface{}) error {
+	src, err := l.opts.sources()
+	if err != nil {
+		return err
+	}
+
+	tree, err := loadDirTree(dir, src)
+	if err != nil {
+		return err
+	}
+
+	return l.load(tree, dst, src)
+}
+
+// LoadTree loads filepath into a TOML tree, as Load does before unmarshaling it into the destination struct.
+func (l *Loader) LoadTree(filepath string) (*toml.Tree, error) {
+	src, err := l.opts.sources()
+	if err != nil {
+		return nil, err
+	}
+
+	return loadTree(filepath, src)
+}
+
+// load unmarshals tree into dst, with the aliases of its fields resolved, then binds "env" and "flag" values and
+// validates the result.
+func (l *Loader) load(tree *toml.Tree, dst interface{}, src *sources) error {
+	src.tree = tree
+
+	if typ := reflect.TypeOf(dst); typ != nil && typ.Kind() == reflect.Ptr && isStructType(typ.Elem()) {
+		resolveAliases(tree, nil, typ.Elem(), "", src)
+	}
+
+	if err := l.unmarshal(tree, dst, src); err != nil {
+		return err
+	}
+
+	if err := bindSources(dst, tree, "", src); err != nil {
+		return err
+	}
+
+	return validate(dst, "", src)
+}
+
+// unmarshal unmarshals tree into dst. The decoder options of go-toml can only be set on a Decoder, which reads the tree
+// back from its TOML encoding. With custom struct-tags, the keys are renamed to the ones the decoder looks for.
+//
+// Errors in the values of the tree are returned as FileErrors when they can be located in the loaded files.
+func (l *Loader) unmarshal(tree *toml.Tree, dst interface{}, src *sources) error {
+	tags := src.tags
+	if !l.opts.strict && tags.isDefault() {
+		if err := tree.Unmarshal(dst); err != nil {
+			return l.locateError(err, tree, nil, dst, src)
+		}
+		return nil
+	}
+
+	b, err := tree.Marshal()
+	if err != nil {
+		return err
+	}
+
+	if !tags.isDefault() {
+		renamed, err := toml.LoadBytes(b)
+		if err != nil {
+			return err
+		}
+
+		tags.renameKeys(renamed, reflect.TypeOf(dst))
+
+		if b, err = renamed.Marshal(); err != nil {
+			return err
+		}
+	}
+
+	dec := toml.NewDecoder(bytes.NewReader(b)).SetTagName(tags.decoderTag()).Strict(l.opts.strict)
+	if err := dec.Decode(dst); err != nil {
+		return l.locateError(err, tree, b, dst, src)
+	}
+
+	return nil
+}
+
+// locateError returns the error err of unmarshaling tree into dst as a FileError in the config file the invalid value
+// was read from. The keys of merged files can share a position, so err is located in the TOML encoding b the decoder
+// read instead, where every key has a line of its own. If dst was unmarshaled from tree itself, b is nil, and tree is
+// encoded and unmarshaled again. With custom struct-tags, the keys of b are renamed back to the ones of tree.
+//
+// The position of an error that can't be located is in none of the files, so it is dropped.
+func (l *Loader) locateError(err error, tree *toml.Tree, b []byte, dst interface{}, src *sources) error {
+	_, msg, ok := splitPosition(err)
+	if !ok {
+		return err
+	}
+
+	reencode := b == nil
+	if reencode {
+		var merr error
+		if b, merr = tree.Marshal(); merr != nil {
+			return errors.New(msg)
+		}
+	}
+
+	encoded, lerr := toml.LoadBytes(b)
+	if lerr != nil {
+		return errors.New(msg)
+	}
+
+	if reencode {
+		// dst is already partly set, and the load fails anyway.
+		if err = encoded.Unmarshal(dst); err == nil {
+			return errors.New(msg)
+		}
+	}
+
+	pos, msg, ok := splitPosition(err)
+	if !ok {
+		return err
+	}
+
+	path, ok := pathAt(encoded, pos)
+	if !ok {
+		return errors.New(msg)
+	}
+
+	if !src.tags.isDefault() {
+		path = src.tags.originalPath(tree, reflect.TypeOf(dst), path)
+	}
+
+	return src.locateKey(tree, path, msg)
+}
+
+// loadTree loads filepath into a TOML tree, resolving its includes and merging the overrides of the active profile, if
+// profiles are enabled.
+func loadTree(filepath string, src *sources) (*toml.Tree, error) {
+	tree, err := loadFileTree(filepath, nil, src)
+	if err != nil {
+		return nil, err
+	}
+
+	if src.profile == nil {
+		return tree, nil
+	}
+
+	profile := activeProfile(src)
+	if err := applyProfileTable(tree, src.profile.table, profile); err != nil {
+		return nil, err
+	}
+
+	if err := applyProfileFile(tree, filepath, profile, src); err != nil {
+		return nil, err
+	}
+
+	return tree, nil
+}
+
+// loadDirTree loads all *.toml files in dir into a TOML tree, deep-merging them in lexical order. If profiles are
+// enabled, the overrides of the active profile are merged from the profile tables of the files.
+func loadDirTree(dir string, src *sources) (*toml.Tree, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.toml"))
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := toml.TreeFromMap(map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		fragment, err := loadFileTree(file, nil, src)
+		if err != nil {
+			if isFileError(err) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%v: %v", file, err)
+		}
+
+		mergeTrees(tree, fragment)
+	}
+
+	if src.profile == nil {
+		return tree, nil
+	}
+
+	if err := applyProfileTable(tree, src.profile.table, activeProfile(src)); err != nil {
+		return nil, err
+	}
+
+	return tree, nil
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLoader_WithFlagSet(t *testing.T) {
+	var cfg struct {
+		Hostname string `toml:"host_name" flag:"host-name"`
+		Port     int    `toml:"port" flag:"port"`
+	}
+
+	// flag.CommandLine doesn't define the flags
+	flag.CommandLine = flag.NewFlagSet("tmp", flag.ExitOnError)
+
+	fs := flag.NewFlagSet("loader", flag.ContinueOnError)
+	_ = fs.String("host-name", "default.example.com", "")
+	_ = fs.Int("port", 8080, "")
+	if err := fs.Parse([]string{"-port", "9090"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if err := New(WithFlagSet(fs)).Load(tmp.Name(), &cfg); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if cfg.Hostname != "default.example.com" {
+		t.Errorf("got: %v, expected: %v", cfg.Hostname, "default.example.com")
+	}
+
+	if cfg.Port != 9090 {
+		t.Errorf("got: %v, expected: %v", cfg.Port, 9090)
+	}
+}
+
+func TestLoader_WithStrict(t *testing.T) {
+	type dbConfig struct {
+		Host string `toml:"host"`
+	}
+
+	var cfg struct {
+		Name string    `toml:"name"`
+		DB   *dbConfig `toml:"database"`
+	}
+
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString("name = \"example\"\n\n[database]\nhost = \"localhost\"\nhots = \"typo\"\n"); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	// Unknown keys are ignored by default
+	if err := New().Load(tmp.Name(), &cfg); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	err := New(WithStrict()).Load(tmp.Name(), &cfg)
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+
+	if !strings.Contains(err.Error(), "database.hots") {
+		t.Errorf("got: %v, expected error about database.hots", err)
+	}
+}
+
+func TestLoader_Reuse(t *testing.T) {
+	os.Clearenv()
+	flag.CommandLine = flag.NewFlagSet("tmp", flag.ExitOnError)
+
+	type testConfig struct {
+		Name string `toml:"name" env:"NAME"`
+	}
+
+	l := New(WithEnv(map[string]string{"NAME": "env"}), WithStrict())
+
+	for _, content := range []string{`name = "first"`, `name = "second"`} {
+		tmp, _ := ioutil.TempFile("", "")
+		defer os.Remove(tmp.Name())
+
+		if _, err := tmp.WriteString(content); err != nil {
+			t.Fatalf("write config file failed: %v", err)
+		}
+
+		var cfg testConfig
+		if err := l.Load(tmp.Name(), &cfg); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		if cfg.Name != "env" {
+			t.Errorf("got: %v, expected: %v", cfg.Name, "env")
+		}
+	}
+}
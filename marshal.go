@@ -0,0 +1,248 @@
+package config
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml"
+)
+
+const (
+	secretTag string = "secret"
+
+	// RedactedValue replaces the values of secret fields when marshaling with Redact.
+	RedactedValue string = "REDACTED"
+)
+
+// MarshalOption configures Marshal and Save.
+type MarshalOption func(*marshalOptions)
+
+type marshalOptions struct {
+	omitEmpty bool
+	redact    bool
+}
+
+// OmitEmpty skips fields with zero values, as if all of them had the "omitempty" toml option.
+func OmitEmpty() MarshalOption {
+	return func(o *marshalOptions) {
+		o.omitEmpty = true
+	}
+}
+
+// Redact replaces the values of fields tagged with `secret:"true"` with RedactedValue.
+func Redact() MarshalOption {
+	return func(o *marshalOptions) {
+		o.redact = true
+	}
+}
+
+// Marshal returns the TOML encoding of cfg, which should be a struct or a pointer to a struct. Keys are named after
+// the "toml" struct tags Load honors, falling back to the field name. Fields tagged with `toml:"-"` are skipped.
+func Marshal(cfg interface{}, opts ...MarshalOption) ([]byte, error) {
+	var o marshalOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	v, err := structValue(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := marshalStruct(v, o)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := toml.TreeFromMap(m)
+	if err != nil {
+		return nil, err
+	}
+
+	return tree.Marshal()
+}
+
+// Save marshals cfg and writes it to filepath.
+func Save(filepath string, cfg interface{}, opts ...MarshalOption) error {
+	b, err := Marshal(cfg, opts...)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath, b, 0600)
+}
+
+// structValue dereferences cfg and checks that it is a struct.
+func structValue(cfg interface{}) (reflect.Value, error) {
+	v := reflect.ValueOf(cfg)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return v, errors.New("nil pointer given as config")
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return v, fmt.Errorf("config should be a struct, got %v", v.Kind())
+	}
+
+	return v, nil
+}
+
+// marshalStruct converts the exported fields of v into a map keyed by their toml names.
+func marshalStruct(v reflect.Value, o marshalOptions) (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name, omitEmpty := parseTomlTag(field)
+		if name == "-" {
+			continue
+		}
+
+		fv := v.Field(i)
+		if (omitEmpty || o.omitEmpty) && fv.IsZero() {
+			continue
+		}
+
+		if o.redact && isSecret(field) {
+			m[name] = RedactedValue
+			continue
+		}
+
+		val, ok, err := marshalValue(fv, o)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", field.Name, err)
+		}
+
+		if !ok {
+			continue
+		}
+
+		// Embedded structs without a toml name are flattened into the parent, the way go-toml decodes them. Embedded
+		// pointers are decoded from their own table.
+		flatten := field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get(tomlTag) == ""
+		if nested, isMap := val.(map[string]interface{}); isMap && flatten {
+			for k, nv := range nested {
+				m[k] = nv
+			}
+			continue
+		}
+
+		if nested, isMap := val.(map[string]interface{}); isMap && o.omitEmpty && len(nested) == 0 {
+			continue
+		}
+
+		m[name] = val
+	}
+
+	return m, nil
+}
+
+// marshalValue converts v into a value accepted by toml.TreeFromMap. It returns false if v should be skipped.
+func marshalValue(v reflect.Value, o marshalOptions) (interface{}, bool, error) {
+	if v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, false, nil
+		}
+		return marshalValue(v.Elem(), o)
+	}
+
+	switch val := v.Interface().(type) {
+	case time.Time:
+		return val, true, nil
+	case time.Duration:
+		return val.String(), true, nil
+	}
+
+	m, ok := v.Interface().(encoding.TextMarshaler)
+	if !ok && v.CanAddr() {
+		// Fields of configs given by pointer can have TextMarshalers with pointer receivers
+		m, ok = v.Addr().Interface().(encoding.TextMarshaler)
+	}
+
+	if ok {
+		b, err := m.MarshalText()
+		if err != nil {
+			return nil, false, err
+		}
+		return string(b), true, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool(), true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint(), true, nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true, nil
+	case reflect.String:
+		return v.String(), true, nil
+	case reflect.Struct:
+		m, err := marshalStruct(v, o)
+		return m, err == nil, err
+	case reflect.Slice, reflect.Array:
+		s := make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			val, ok, err := marshalValue(v.Index(i), o)
+			if err != nil {
+				return nil, false, err
+			}
+			if ok {
+				s = append(s, val)
+			}
+		}
+		return s, true, nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, false, fmt.Errorf("unhandled map key type %v", v.Type().Key())
+		}
+		m := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			val, ok, err := marshalValue(iter.Value(), o)
+			if err != nil {
+				return nil, false, err
+			}
+			if ok {
+				m[iter.Key().String()] = val
+			}
+		}
+		return m, true, nil
+	}
+
+	return nil, false, fmt.Errorf("unhandled type %v", v.Kind())
+}
+
+// parseTomlTag returns the toml key of field and whether it has the "omitempty" option.
+func parseTomlTag(field reflect.StructField) (string, bool) {
+	parts := strings.Split(field.Tag.Get(tomlTag), ",")
+
+	name := strings.TrimSpace(parts[0])
+	if name == "" {
+		name = field.Name
+	}
+
+	omitEmpty := len(parts) > 1 && strings.TrimSpace(parts[1]) == "omitempty"
+
+	return name, omitEmpty
+}
+
+// isSecret will check if field is tagged as secret
+func isSecret(field reflect.StructField) bool {
+	secret, _ := strconv.ParseBool(field.Tag.Get(secretTag))
+	return secret
+}
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type marshalTestConfig struct {
+	Name     string        `toml:"name"`
+	Interval time.Duration `toml:"interval"`
+	Started  time.Time     `toml:"started"`
+	Port     int           `toml:"-"`
+	Tags     []string      `toml:"tags"`
+	Server   struct {
+		Host string `toml:"host"`
+		Port int    `toml:"port"`
+	} `toml:"server"`
+	DB *struct {
+		User     string `toml:"user"`
+		Password string `toml:"password" secret:"true"`
+	} `toml:"database"`
+}
+
+func TestMarshal_RoundTrip(t *testing.T) {
+	var cfg marshalTestConfig
+	cfg.Name = "example"
+	cfg.Interval = 90 * time.Second
+	cfg.Started = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	cfg.Port = 8080
+	cfg.Tags = []string{"a", "b"}
+	cfg.Server.Host = "localhost"
+	cfg.Server.Port = 1010
+	cfg.DB = &struct {
+		User     string `toml:"user"`
+		Password string `toml:"password" secret:"true"`
+	}{User: "admin", Password: "12345"}
+
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if err := Save(tmp.Name(), &cfg); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	var got marshalTestConfig
+	if err := Load(tmp.Name(), &got); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	want := cfg
+	want.Port = 0 // not marshaled, toml:"-"
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%v", diff)
+	}
+}
+
+func TestMarshal_PointerAndValue(t *testing.T) {
+	cfg := marshalTestConfig{
+		Name:     "example",
+		Interval: time.Minute,
+		Started:  time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	byValue, err := Marshal(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	byPointer, err := Marshal(&cfg)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if diff := cmp.Diff(string(byValue), string(byPointer)); diff != "" {
+		t.Errorf("mismatch (-value +pointer):\n%v", diff)
+	}
+
+	if !strings.Contains(string(byPointer), "started = 2020-01-02T03:04:05Z\n") {
+		t.Errorf("expected started as a TOML datetime, got:\n%s", byPointer)
+	}
+}
+
+func TestMarshal_OmitEmpty(t *testing.T) {
+	var cfg marshalTestConfig
+	cfg.Server.Host = "localhost"
+
+	b, err := Marshal(cfg, OmitEmpty())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	got := strings.TrimSpace(string(b))
+	expected := "[server]\n  host = \"localhost\""
+	if got != expected {
+		t.Errorf("got: %q, expected: %q", got, expected)
+	}
+}
+
+func TestMarshal_Redact(t *testing.T) {
+	var cfg marshalTestConfig
+	cfg.DB = &struct {
+		User     string `toml:"user"`
+		Password string `toml:"password" secret:"true"`
+	}{User: "admin", Password: "12345"}
+
+	b, err := Marshal(&cfg, Redact())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if strings.Contains(string(b), "12345") {
+		t.Errorf("secret is not redacted:\n%s", b)
+	}
+
+	if !strings.Contains(string(b), `password = "`+RedactedValue+`"`) {
+		t.Errorf("expected redacted password, got:\n%s", b)
+	}
+
+	if !strings.Contains(string(b), `user = "admin"`) {
+		t.Errorf("expected user, got:\n%s", b)
+	}
+}
+
+func TestMarshal_ErrorIfNotStruct(t *testing.T) {
+	if _, err := Marshal(42); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+
+	var cfg *marshalTestConfig
+	if _, err := Marshal(cfg); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+}
@@ -2,53 +2,172 @@ package config
 
 import (
 	"context"
+	"errors"
+	"os"
+	"os/signal"
 	"path/filepath"
+	"sync"
+	"syscall"
 
 	"github.com/fsnotify/fsnotify"
 )
 
+// ErrWatcherClosed is the terminal error of a Watch channel. It is sent when the underlying file watcher stops
+// unexpectedly, right before the channel is closed. Any other error received from the channel is transient.
+var ErrWatcherClosed = errors.New("config: file watcher closed")
+
 // Watch starts watching the given file for changes, and returns a channel to get notified on.
 // Errors are also passed through this channel: Receiving a nil from the channel indicates the file is updated.
+//
+// If pathtofile is a directory, as used by LoadDir, adding, updating or removing a *.toml file in it is notified.
+//
+// The channel is closed when ctx is done or when the watcher dies. In the latter case ErrWatcherClosed is sent
+// before closing the channel.
 func Watch(ctx context.Context, pathtofile string) (<-chan error, error) {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, err
 	}
 
-	absfile, err := filepath.Abs(pathtofile)
+	abspath, err := filepath.Abs(pathtofile)
 	if err != nil {
+		watcher.Close()
 		return nil, err
 	}
-	basedir := filepath.Dir(absfile)
 
-	if err = watcher.Add(basedir); err != nil {
+	match := matchFile(abspath)
+	watchdir := filepath.Dir(abspath)
+	if fi, err := os.Stat(abspath); err == nil && fi.IsDir() {
+		match = matchDir(abspath)
+		watchdir = abspath
+	}
+
+	if err = watcher.Add(watchdir); err != nil {
+		watcher.Close()
 		return nil, err
 	}
 
+	return watch(ctx, watcher, match), nil
+}
+
+// matchFile returns a filter for events that update absfile.
+func matchFile(absfile string) func(fsnotify.Event) bool {
+	return func(e fsnotify.Event) bool {
+		return e.Op&(fsnotify.Create|fsnotify.Write) > 0 && e.Name == absfile
+	}
+}
+
+// matchDir returns a filter for events that add, update or remove a *.toml file in absdir.
+func matchDir(absdir string) func(fsnotify.Event) bool {
+	return func(e fsnotify.Event) bool {
+		if e.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Remove|fsnotify.Rename) == 0 {
+			return false
+		}
+
+		return filepath.Dir(e.Name) == absdir && filepath.Ext(e.Name) == ".toml"
+	}
+}
+
+// watch runs the event loop of watcher in a new goroutine, notifying the events accepted by match. The watcher is
+// closed when the loop ends.
+func watch(ctx context.Context, watcher *fsnotify.Watcher, match func(fsnotify.Event) bool) <-chan error {
 	writech := make(chan error, 100)
 
 	go func() {
+		defer close(writech)
+		defer watcher.Close()
+
 		for {
 			select {
 			case <-ctx.Done():
-				watcher.Close()
 				return
 
-			case err := <-watcher.Errors:
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					handleNotify(ctx, writech, ErrWatcherClosed)
+					return
+				}
 				handleNotify(ctx, writech, err)
 
-			case e := <-watcher.Events:
-				if e.Op&(fsnotify.Create|fsnotify.Write) > 0 {
-					if e.Name == absfile {
-						handleNotify(ctx, writech, nil)
-					}
+			case e, ok := <-watcher.Events:
+				if !ok {
+					handleNotify(ctx, writech, ErrWatcherClosed)
+					return
+				}
+				if match(e) {
+					handleNotify(ctx, writech, nil)
 				}
 			}
 
 		}
 	}()
 
-	return writech, nil
+	return writech
+}
+
+// WatchSignals returns a channel to get notified on when the process receives one of the given signals, which
+// defaults to SIGHUP. Notifications are the same as the ones produced by Watch, so a nil from the channel means the
+// configuration should be reloaded. The channel is closed when ctx is done.
+func WatchSignals(ctx context.Context, sig ...os.Signal) <-chan error {
+	if len(sig) == 0 {
+		sig = []os.Signal{syscall.SIGHUP}
+	}
+
+	sigch := make(chan os.Signal, 1)
+	signal.Notify(sigch, sig...)
+
+	writech := make(chan error, 100)
+
+	go func() {
+		defer close(writech)
+		defer signal.Stop(sigch)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case <-sigch:
+				handleNotify(ctx, writech, nil)
+			}
+		}
+	}()
+
+	return writech
+}
+
+// Merge combines the notification channels returned by Watch and WatchSignals into a single channel, so file
+// changes and signals can be handled in the same reload loop. The returned channel is closed when all of the given
+// channels are closed or when ctx is done.
+func Merge(ctx context.Context, chs ...<-chan error) <-chan error {
+	writech := make(chan error, 100)
+
+	var wg sync.WaitGroup
+	wg.Add(len(chs))
+	for _, ch := range chs {
+		go func(ch <-chan error) {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+
+				case e, ok := <-ch:
+					if !ok {
+						return
+					}
+					handleNotify(ctx, writech, e)
+				}
+			}
+		}(ch)
+	}
+
+	go func() {
+		wg.Wait()
+		close(writech)
+	}()
+
+	return writech
 }
 
 func handleNotify(ctx context.Context, ch chan<- error, val error) {
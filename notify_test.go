@@ -2,10 +2,17 @@ package config
 
 import (
 	"context"
+	"errors"
 	"io/ioutil"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"syscall"
 	"testing"
 	"time"
+
+	"github.com/fsnotify/fsnotify"
 )
 
 func TestNotify(t *testing.T) {
@@ -66,3 +73,217 @@ func TestNotify(t *testing.T) {
 		t.Fatalf("got: %v, expected: %v", cfg.Key, "ho")
 	}
 }
+
+func TestNotify_ClosedOnContextDone(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "")
+	tmp, _ := ioutil.TempFile(dir, "")
+	defer os.RemoveAll(dir)
+	tmp.Close()
+
+	before := runtime.NumGoroutine()
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	watch, err := Watch(ctx, tmp.Name())
+	if err != nil {
+		t.Fatalf("unexpected error while watching the configuration file: %v", err)
+	}
+
+	cancelFunc()
+
+	select {
+	case _, ok := <-watch:
+		if ok {
+			t.Fatalf("expected channel to be closed")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("channel is not closed after context is canceled")
+	}
+
+	waitGoroutines(t, before)
+}
+
+func TestNotify_WatcherClosed(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "")
+	tmp, _ := ioutil.TempFile(dir, "")
+	defer os.RemoveAll(dir)
+	tmp.Close()
+
+	before := runtime.NumGoroutine()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := watcher.Add(dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ch := watch(context.Background(), watcher, matchFile(tmp.Name()))
+
+	watcher.Close()
+
+	select {
+	case err := <-ch:
+		if !errors.Is(err, ErrWatcherClosed) {
+			t.Fatalf("got: %v, expected: %v", err, ErrWatcherClosed)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("no terminal error after watcher is closed")
+	}
+
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Fatalf("expected channel to be closed")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("channel is not closed after watcher is closed")
+	}
+
+	waitGoroutines(t, before)
+}
+
+func TestNotify_WatchErrorDoesNotLeak(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	if _, err := Watch(context.Background(), "/nonexistent/dir/config.toml"); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+
+	waitGoroutines(t, before)
+}
+
+// waitGoroutines fails the test if the number of goroutines does not drop back to n in a reasonable time.
+func waitGoroutines(t *testing.T, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for runtime.NumGoroutine() > n {
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutine leak: got %v goroutines, expected at most %v", runtime.NumGoroutine(), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWatchSignals(t *testing.T) {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancelFunc()
+
+	ch := WatchSignals(ctx, syscall.SIGHUP)
+
+	p, _ := os.FindProcess(os.Getpid())
+	if err := p.Signal(syscall.SIGHUP); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case <-ctx.Done():
+		t.Fatalf("context canceled: %v", ctx.Err())
+	case err := <-ch:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	// The first signal.Notify starts a goroutine in os/signal that never exits, so start it before counting.
+	sigch := make(chan os.Signal, 1)
+	signal.Notify(sigch, syscall.SIGHUP)
+	signal.Stop(sigch)
+
+	before := runtime.NumGoroutine()
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+
+	// A file change can notify more than once, so the file side is faked to prove the signal goes through Merge.
+	fileCh := make(chan error)
+	ch := Merge(ctx, fileCh, WatchSignals(ctx, syscall.SIGHUP))
+
+	fileErr := errors.New("file changed")
+	fileCh <- fileErr
+
+	select {
+	case err := <-ch:
+		if err != fileErr {
+			t.Fatalf("got: %v, expected: %v", err, fileErr)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("no notification after file update")
+	}
+
+	p, _ := os.FindProcess(os.Getpid())
+	if err := p.Signal(syscall.SIGHUP); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case err := <-ch:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("no notification after signal")
+	}
+
+	cancelFunc()
+
+	for range ch {
+	}
+
+	waitGoroutines(t, before)
+}
+
+func TestNotify_Dir(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(dir)
+
+	ctx, cancelFunc := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancelFunc()
+
+	watch, err := Watch(ctx, dir)
+	if err != nil {
+		t.Fatalf("unexpected error while watching the configuration directory: %v", err)
+	}
+
+	wait := func(what string) {
+		t.Helper()
+		select {
+		case <-ctx.Done():
+			t.Fatalf("no notification after %v", what)
+		case err := <-watch:
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+	}
+
+	fragment := filepath.Join(dir, "10-fragment.toml")
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "ignored.txt"), []byte(`ignored`), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if len(watch) > 0 {
+		t.Fatalf("got notification for a file other than *.toml")
+	}
+
+	if err := ioutil.WriteFile(fragment, []byte(`key = "hey"`), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wait("adding a fragment")
+
+	// Drain the write events of the fragment
+	time.Sleep(100 * time.Millisecond)
+	for len(watch) > 0 {
+		<-watch
+	}
+
+	if err := os.Remove(fragment); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wait("removing a fragment")
+}
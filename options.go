@@ -0,0 +1,285 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+// Source is a source of values for the fields of a config struct.
+type Source int
+
+const (
+	// SourceNone is the zero value, for values that are not read from any source.
+	SourceNone Source = iota
+	// SourceFlag is a CLI flag given on the command line, bound by the "flag" struct-tag.
+	SourceFlag
+	// SourceEnv is an environment variable, bound by the "env" struct-tag.
+	SourceEnv
+	// SourceFile is a key in the config file, bound by the "toml" struct-tag.
+	SourceFile
+	// SourceDefault is the default value of a flag. It always has the lowest precedence, so it can't be given to
+	// WithPrecedence.
+	SourceDefault
+)
+
+// defaultPrecedence is the precedence of sources without WithPrecedence.
+var defaultPrecedence = []Source{SourceFlag, SourceEnv, SourceFile}
+
+func (s Source) String() string {
+	switch s {
+	case SourceNone:
+		return "none"
+	case SourceFlag:
+		return "flag"
+	case SourceEnv:
+		return "env"
+	case SourceFile:
+		return "file"
+	case SourceDefault:
+		return "default"
+	}
+	return fmt.Sprintf("Source(%d)", int(s))
+}
+
+// Option configures a Loader.
+type Option func(*options)
+
+// options are the settings collected from Option values.
+type options struct {
+	lookupEnv   func(string) (string, bool)
+	envNames    func() []string
+	dotenvFiles []string
+	flags       *flag.FlagSet
+	precedence  []Source
+	tags        tagNames
+	strict      bool
+	validation  bool
+	logger      Logger
+	profile     *profileNames
+}
+
+// Logger writes the warnings of loading, such as the use of deprecated names. *log.Logger is a Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// stdLogger is a Logger that writes to the standard logger of the log package.
+type stdLogger struct{}
+
+func (stdLogger) Printf(format string, v ...interface{}) {
+	log.Printf(format, v...)
+}
+
+// WithEnvLookup resolves environment variables with lookupEnv instead of os.LookupEnv. The variables can't be listed, so
+// overrides of map keys that are not in the file are not found.
+func WithEnvLookup(lookupEnv func(key string) (string, bool)) Option {
+	return func(o *options) {
+		o.lookupEnv = lookupEnv
+		o.envNames = nil
+	}
+}
+
+// WithEnv resolves environment variables from env instead of the process environment.
+func WithEnv(env map[string]string) Option {
+	return func(o *options) {
+		o.lookupEnv = func(key string) (string, bool) {
+			value, ok := env[key]
+			return value, ok
+		}
+		o.envNames = func() []string {
+			names := make([]string, 0, len(env))
+			for k := range env {
+				names = append(names, k)
+			}
+			return names
+		}
+	}
+}
+
+// WithDotenv reads environment variables from the given dotenv (.env) files, which are skipped if they don't exist.
+// Variables in later files override earlier ones, and variables in the environment (see WithEnvLookup) override all
+// files. The process environment is not modified.
+func WithDotenv(files ...string) Option {
+	return func(o *options) {
+		o.dotenvFiles = append(o.dotenvFiles, files...)
+	}
+}
+
+// WithFlagSet binds "flag" struct-tags and the flag of WithProfile to fs instead of flag.CommandLine. fs should be
+// parsed before loading.
+func WithFlagSet(fs *flag.FlagSet) Option {
+	return func(o *options) {
+		o.flags = fs
+	}
+}
+
+// WithPrecedence sets the precedence of sources, from highest to lowest. Each of SourceFlag, SourceEnv and SourceFile
+// should be listed once. A field takes its value from the first source that has one, or from the flag default if none
+// has. The default precedence is SourceFlag, SourceEnv, SourceFile.
+func WithPrecedence(order ...Source) Option {
+	return func(o *options) {
+		o.precedence = order
+	}
+}
+
+// WithStrict makes loading fail if the file has keys that don't match any field of the destination struct.
+func WithStrict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// WithValidation makes loading fail if fields tagged `required:"true"` are not set by any source, or fields with an
+// "enum" struct-tag are set to a value it doesn't list. The tags are only documented without it, so they can be shared
+// with other libraries.
+func WithValidation() Option {
+	return func(o *options) {
+		o.validation = true
+	}
+}
+
+// WithLogger writes warnings to logger instead of the standard logger of the log package. A nil logger discards them.
+func WithLogger(logger Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// newOptions applies opts over the defaults.
+func newOptions(opts []Option) options {
+	o := options{
+		lookupEnv:  os.LookupEnv,
+		envNames:   environNames,
+		precedence: defaultPrecedence,
+		tags:       defaultTagNames,
+		logger:     stdLogger{},
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// sources are the environment and flags used by a single load, their precedence, and the struct-tags that bind them.
+type sources struct {
+	lookupEnv  func(string) (string, bool)
+	envNames   func() []string
+	flags      *flag.FlagSet
+	precedence []Source
+	tags       tagNames
+	logger     Logger
+	profile    *profileNames // nil if profiles are not enabled
+	validation bool          // check the "required" and "enum" struct-tags
+
+	// setFlags are the names of the flags of flags given on the command line, visited once per load.
+	setFlags map[string]bool
+
+	// envPrefix and flagPrefix are prepended to the "env" and "flag" struct-tags of the elements of slices, e.g.
+	// "APP_SERVERS_0_" and "servers.0.". The struct-tags of elements are ignored if their prefix is empty.
+	indexed    bool
+	envPrefix  string
+	flagPrefix string
+
+	// path is the path of the element in the config file, e.g. "servers[0]", which prefixes the paths of its fields in
+	// errors. origins are where the values of the fields bound so far were read from, by path, shared by the sources
+	// of the elements.
+	path    string
+	origins map[string]origin
+
+	// tree is the loaded config file, to check which keys are set.
+	tree *toml.Tree
+
+	// files are the config files that were loaded, in the order they were merged, to locate the errors in their values.
+	files []string
+}
+
+// origin is where the value of a field was read from.
+type origin struct {
+	source Source
+	key    string
+	value  string
+}
+
+// sources reads the dotenv files and resolves the flag set. They are resolved on every load, so that reloads pick up
+// changes to the dotenv files and to flag.CommandLine.
+func (o options) sources() (*sources, error) {
+	if err := validatePrecedence(o.precedence); err != nil {
+		return nil, err
+	}
+
+	s := &sources{
+		lookupEnv:  o.lookupEnv,
+		envNames:   o.envNames,
+		flags:      o.flags,
+		precedence: o.precedence,
+		tags:       o.tags,
+		logger:     o.logger,
+		profile:    o.profile,
+		validation: o.validation,
+		origins:    make(map[string]origin),
+	}
+
+	if s.flags == nil {
+		s.flags = flag.CommandLine
+	}
+
+	s.setFlags = make(map[string]bool)
+	s.flags.Visit(func(fl *flag.Flag) {
+		s.setFlags[fl.Name] = true
+	})
+
+	if len(o.dotenvFiles) > 0 {
+		lookupEnv, envNames, err := dotenvLookup(o.lookupEnv, o.envNames, o.dotenvFiles)
+		if err != nil {
+			return nil, err
+		}
+		s.lookupEnv, s.envNames = lookupEnv, envNames
+	}
+
+	return s, nil
+}
+
+// isFlagSet will check if the flag name is given on the command line
+func (s *sources) isFlagSet(name string) bool {
+	return s.setFlags[name]
+}
+
+// environNames returns the names of the variables in the process environment.
+func environNames() []string {
+	environ := os.Environ()
+	names := make([]string, 0, len(environ))
+	for _, kv := range environ {
+		if i := strings.Index(kv, "="); i > 0 {
+			names = append(names, kv[:i])
+		}
+	}
+	return names
+}
+
+// validatePrecedence will check if order lists each source once
+func validatePrecedence(order []Source) error {
+	seen := make(map[Source]bool)
+	for _, s := range order {
+		if s != SourceFlag && s != SourceEnv && s != SourceFile {
+			return fmt.Errorf("invalid precedence: unknown source %v", s)
+		}
+
+		if seen[s] {
+			return fmt.Errorf("invalid precedence: source %v is listed more than once", s)
+		}
+		seen[s] = true
+	}
+
+	if len(seen) != len(defaultPrecedence) {
+		return fmt.Errorf("invalid precedence %v: each of flag, env and file should be listed", order)
+	}
+
+	return nil
+}
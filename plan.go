@@ -0,0 +1,194 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// plans caches the compiled plans of struct types, by planKey.
+var plans sync.Map
+
+// planKey identifies a plan: the struct-tags of a type are read by their names in tags.
+type planKey struct {
+	typ  reflect.Type
+	tags tagNames
+}
+
+// typePlan is what a load needs to know about the fields of a struct type, compiled from its struct-tags once, so that
+// repeated loads don't parse struct-tags or look up fields by name.
+type typePlan struct {
+	fields []fieldPlan
+}
+
+// fieldPlan is the compiled form of an exported struct field.
+type fieldPlan struct {
+	// index is the index path of the field in the struct, longer than one for the fields promoted from unexported
+	// embedded structs.
+	index []int
+	name  string
+	typ   reflect.Type
+
+	// envKey and flagName are the "env" and "flag" struct-tags, empty if the field has none or they are "-". tomlName is
+	// the name in the "toml" struct-tag, and pathName is the key of the field in the file, which is tomlName or the
+	// field name, empty for embedded structs that are flattened into the parent. Embedded pointers to structs are
+	// decoded from a table named after their type, as go-toml does.
+	envKey   string
+	flagName string
+	tomlName string
+	pathName string
+
+	structSlice bool
+	structMap   bool
+	nested      bool // struct or pointer to struct
+	iface       bool // interface, walked if it holds a struct
+
+	required   bool
+	hasDefault bool // has a "default" struct-tag
+	enum       string
+	min        string
+	max        string
+
+	// aliases, envAliases and flagAliases are the old names of the field in the file, the environment and the flags,
+	// from the "aliases" struct-tag, and deprecated is the warning given when they are used, or when the field is set
+	// if it has no aliases.
+	aliases     []string
+	envAliases  []string
+	flagAliases []string
+	deprecated  string
+}
+
+// planFor returns the plan of the struct type typ with the struct-tags named in tags, compiling it on first use.
+// Nested types are compiled when they are first walked.
+func planFor(typ reflect.Type, tags tagNames) *typePlan {
+	key := planKey{typ: typ, tags: tags}
+	if p, ok := plans.Load(key); ok {
+		return p.(*typePlan)
+	}
+
+	p, _ := plans.LoadOrStore(key, compilePlan(typ, tags))
+	return p.(*typePlan)
+}
+
+// compilePlan compiles the plan of the struct type typ.
+func compilePlan(typ reflect.Type, tags tagNames) *typePlan {
+	p := &typePlan{}
+	p.compileFields(typ, typ, nil, tags)
+	return p
+}
+
+// compileFields appends the plans of the fields of typ, which is at index in root. Unexported embedded structs are
+// skipped like other unexported fields, but their exported fields are promoted into root, as Go does, since they can
+// be set through it. Promoted fields that are shadowed by the fields of root are skipped.
+func (p *typePlan) compileFields(root, typ reflect.Type, index []int, tags tagNames) {
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		sf.Index = append(append([]int(nil), index...), i)
+
+		if sf.PkgPath != "" {
+			if sf.Anonymous && isStructType(derefType(sf.Type)) {
+				p.compileFields(root, derefType(sf.Type), sf.Index, tags)
+			}
+			continue
+		}
+
+		if len(index) > 0 {
+			if visible, ok := root.FieldByName(sf.Name); !ok || !reflect.DeepEqual(visible.Index, sf.Index) {
+				continue
+			}
+		}
+
+		p.fields = append(p.fields, compileField(sf, tags))
+	}
+}
+
+// compileField compiles the plan of sf, whose Index is its index path in the struct of the plan.
+func compileField(sf reflect.StructField, tags tagNames) fieldPlan {
+	fp := fieldPlan{
+		index:       sf.Index,
+		name:        sf.Name,
+		typ:         sf.Type,
+		envKey:      tags.get(sf.Tag.Get, envTag),
+		flagName:    tags.get(sf.Tag.Get, flagTag),
+		structSlice: isStructSliceType(sf.Type),
+		structMap:   isStructMapType(sf.Type),
+		nested:      isStructType(derefType(sf.Type)),
+		iface:       sf.Type.Kind() == reflect.Interface,
+		required:    isRequired(sf.Tag.Get(requiredTag)),
+		enum:        sf.Tag.Get(enumTag),
+		min:         sf.Tag.Get(minTag),
+		max:         sf.Tag.Get(maxTag),
+		deprecated:  sf.Tag.Get(deprecatedTag),
+	}
+
+	_, fp.hasDefault = sf.Tag.Lookup(defaultTag)
+
+	if fp.envKey == "-" {
+		fp.envKey = ""
+	}
+	if fp.flagName == "-" {
+		fp.flagName = ""
+	}
+
+	fp.compileAliases(sf.Tag.Get(aliasesTag))
+
+	fp.tomlName, _ = tags.tomlName(sf.Tag.Get)
+	fp.pathName = fp.tomlName
+	if fp.pathName == "" && !(sf.Anonymous && sf.Type.Kind() == reflect.Struct) {
+		fp.pathName = sf.Name
+	}
+
+	return fp
+}
+
+// field returns the field of fp in the struct v. It returns false if the field is promoted through a nil pointer to an
+// unexported embedded struct, which can't be allocated.
+func (fp *fieldPlan) field(v reflect.Value) (reflect.Value, bool) {
+	for i, x := range fp.index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// ifaceStruct returns the struct held by the interface dstElem, directly or through a pointer, and a function that
+// stores it back. Structs held by value can't be set, so a copy of them is returned. It returns false if dstElem holds
+// anything else.
+func ifaceStruct(dstElem reflect.Value) (reflect.Value, func(), bool) {
+	if dstElem.IsNil() {
+		return reflect.Value{}, nil, false
+	}
+
+	held := dstElem.Elem()
+	if held.Kind() == reflect.Ptr {
+		if held.IsNil() || !isStructType(held.Type().Elem()) {
+			return reflect.Value{}, nil, false
+		}
+		return held.Elem(), func() {}, true
+	}
+
+	if !isStructType(held.Type()) {
+		return reflect.Value{}, nil, false
+	}
+
+	elem := reflect.New(held.Type()).Elem()
+	elem.Set(held)
+	return elem, func() { dstElem.Set(elem) }, true
+}
+
+// nestedPath returns the path in the config file of the nested struct fp, under the table at fieldPath. Embedded
+// structs without a toml name are flattened into the parent.
+func (fp *fieldPlan) nestedPath(fieldPath string) string {
+	switch {
+	case fp.pathName == "":
+		return fieldPath
+	case fieldPath == "":
+		return fp.pathName
+	}
+	return fmt.Sprintf("%s.%s", fieldPath, fp.pathName)
+}
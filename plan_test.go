@@ -0,0 +1,303 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type benchmarkDatabase struct {
+	Host     string `toml:"host" env:"DB_HOST" flag:"db-host"`
+	Port     int    `toml:"port" env:"DB_PORT" flag:"db-port"`
+	User     string `toml:"user" env:"DB_USER"`
+	Password string `toml:"password" env:"DB_PASSWORD" secret:"true"`
+	Name     string `toml:"name" required:"true"`
+}
+
+type benchmarkConfig struct {
+	Name     string             `toml:"name" env:"NAME" flag:"name" required:"true"`
+	Host     string             `toml:"host" env:"HOST" flag:"host"`
+	Port     int                `toml:"port" env:"PORT" flag:"port"`
+	Level    string             `toml:"level" env:"LEVEL" flag:"level" enum:"debug,info,warn,error"`
+	Workers  int                `toml:"workers" env:"WORKERS" flag:"workers" min:"1" max:"64"`
+	Debug    bool               `toml:"debug" env:"DEBUG" flag:"debug"`
+	Ratio    float64            `toml:"ratio" env:"RATIO" flag:"ratio"`
+	Memory   ByteSize           `toml:"memory" env:"MEMORY" flag:"memory"`
+	Timeout  *int               `toml:"timeout" env:"TIMEOUT"`
+	Region   string             `toml:"region" env:"REGION"`
+	Zone     string             `toml:"zone" env:"ZONE"`
+	Tags     []string           `toml:"tags"`
+	Labels   map[string]string  `toml:"labels"`
+	Database benchmarkDatabase  `toml:"database"`
+	Replica  *benchmarkDatabase `toml:"replica"`
+}
+
+const benchmarkFile = `
+name = "example"
+host = "localhost"
+port = 8080
+level = "info"
+workers = 8
+tags = ["a", "b"]
+
+[labels]
+team = "platform"
+
+[database]
+host = "10.0.0.1"
+port = 5432
+name = "app"
+
+[replica]
+host = "10.0.0.2"
+name = "app"
+`
+
+func TestPlanFor(t *testing.T) {
+	typ := reflect.TypeOf(benchmarkConfig{})
+
+	p := planFor(typ, defaultTagNames)
+	if got := planFor(typ, defaultTagNames); got != p {
+		t.Errorf("expected the plan to be cached")
+	}
+
+	custom := defaultTagNames
+	custom.env = "environ"
+	if got := planFor(typ, custom); got == p {
+		t.Errorf("expected a plan per struct-tag names")
+	}
+
+	fp := p.fields[0]
+	expected := fieldPlan{
+		index:    []int{0},
+		name:     "Name",
+		typ:      reflect.TypeOf(""),
+		envKey:   "NAME",
+		flagName: "name",
+		tomlName: "name",
+		pathName: "name",
+		required: true,
+	}
+	if !reflect.DeepEqual(fp, expected) {
+		t.Errorf("got: %+v, expected: %+v", fp, expected)
+	}
+}
+
+func TestLoad_Concurrent(t *testing.T) {
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(benchmarkFile); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	fs := benchmarkFlags(t)
+
+	t.Run("group", func(t *testing.T) {
+		for i := 0; i < 8; i++ {
+			region := fmt.Sprintf("region-%d", i)
+			t.Run(region, func(t *testing.T) {
+				t.Parallel()
+
+				var cfg benchmarkConfig
+				if err := Load(tmp.Name(), &cfg, WithFlagSet(fs), WithEnv(map[string]string{"REGION": region})); err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+
+				if cfg.Region != region || cfg.Replica == nil || cfg.Replica.Host != "10.0.0.2" {
+					t.Errorf("got: %+v, expected region %v and the replica from the file", cfg, region)
+				}
+			})
+		}
+	})
+}
+
+// benchmarkFlags returns a flag set with the flags of benchmarkConfig and unrelated flags, some of them given.
+func benchmarkFlags(tb testing.TB) *flag.FlagSet {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	_ = fs.String("name", "", "")
+	_ = fs.String("host", "", "")
+	_ = fs.Int("port", 80, "")
+	_ = fs.String("level", "info", "")
+	_ = fs.Int("workers", 4, "")
+	_ = fs.Bool("debug", false, "")
+	_ = fs.Float64("ratio", 0.5, "")
+	_ = fs.String("memory", "64MiB", "")
+	_ = fs.String("db-host", "", "")
+	_ = fs.Int("db-port", 5432, "")
+
+	args := []string{"-port", "9090", "-debug"}
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("other-%d", i)
+		_ = fs.String(name, "", "")
+		args = append(args, "-"+name, "x")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		tb.Fatalf("unexpected error %v", err)
+	}
+
+	return fs
+}
+
+// BenchmarkLoad measures loading a file, including parsing it.
+func BenchmarkLoad(b *testing.B) {
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(benchmarkFile); err != nil {
+		b.Fatalf("write config file failed: %v", err)
+	}
+
+	l := New(WithFlagSet(benchmarkFlags(b)), WithEnv(map[string]string{"REGION": "eu-west-1"}))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var cfg benchmarkConfig
+		if err := l.Load(tmp.Name(), &cfg); err != nil {
+			b.Fatalf("unexpected error %v", err)
+		}
+	}
+}
+
+// BenchmarkBind measures binding and validating an already parsed file, the part of a load that walks the struct.
+func BenchmarkBind(b *testing.B) {
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(benchmarkFile); err != nil {
+		b.Fatalf("write config file failed: %v", err)
+	}
+
+	l := New(WithFlagSet(benchmarkFlags(b)), WithEnv(map[string]string{"REGION": "eu-west-1"}))
+	src, err := l.opts.sources()
+	if err != nil {
+		b.Fatalf("unexpected error %v", err)
+	}
+
+	tree, err := loadTree(tmp.Name(), src)
+	if err != nil {
+		b.Fatalf("unexpected error %v", err)
+	}
+
+	var cfg benchmarkConfig
+	if err := tree.Unmarshal(&cfg); err != nil {
+		b.Fatalf("unexpected error %v", err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		src, _ := l.opts.sources()
+		src.tree = tree
+		if err := bindSources(&cfg, tree, "", src); err != nil {
+			b.Fatalf("unexpected error %v", err)
+		}
+		if err := validate(&cfg, "", src); err != nil {
+			b.Fatalf("unexpected error %v", err)
+		}
+	}
+}
+
+type planTestLogging struct {
+	Level  string `toml:"level" env:"LOG_LEVEL" enum:"debug,info"`
+	Format string `toml:"format" env:"LOG_FORMAT"`
+}
+
+type planTestRetry struct {
+	Attempts int `toml:"attempts" env:"RETRY_ATTEMPTS"`
+}
+
+type planTestDriver struct {
+	DSN string `toml:"dsn" env:"DSN" required:"true"`
+}
+
+func TestLoad_UnexportedEmbedded(t *testing.T) {
+	type testConfig struct {
+		planTestLogging
+		*planTestRetry
+		Format string `toml:"format" env:"FORMAT"` // shadows planTestLogging.Format
+	}
+
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	env := map[string]string{
+		"LOG_LEVEL":      "debug",
+		"LOG_FORMAT":     "text",
+		"FORMAT":         "json",
+		"RETRY_ATTEMPTS": "3", // the pointer is nil, and can't be allocated
+	}
+
+	var cfg testConfig
+	if err := Load(tmp.Name(), &cfg, WithFlagSet(flag.NewFlagSet("tmp", flag.ContinueOnError)), WithEnv(env)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if cfg.Level != "debug" || cfg.Format != "json" || cfg.planTestLogging.Format != "" || cfg.planTestRetry != nil {
+		t.Errorf("got: %+v", cfg)
+	}
+
+	cfg = testConfig{planTestRetry: &planTestRetry{}}
+	env["LOG_LEVEL"] = "trace"
+	err := Load(tmp.Name(), &cfg, WithFlagSet(flag.NewFlagSet("tmp", flag.ContinueOnError)), WithEnv(env), WithValidation())
+	if err == nil || !strings.Contains(err.Error(), "'level'") {
+		t.Errorf("got: %v, expected error about level", err)
+	}
+
+	if cfg.planTestRetry.Attempts != 3 {
+		t.Errorf("got: %v, expected: %v", cfg.planTestRetry.Attempts, 3)
+	}
+}
+
+func TestLoad_InterfaceFields(t *testing.T) {
+	type testConfig struct {
+		Driver  interface{} `toml:"driver"`
+		Backup  interface{} `toml:"backup"`
+		Missing interface{} `toml:"missing"`
+	}
+
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString("[driver]\ndsn = \"file\"\n"); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	cfg := testConfig{
+		Driver: &planTestDriver{},
+		Backup: planTestDriver{}, // held by value
+	}
+
+	env := map[string]string{"DSN": "env"}
+	if err := Load(tmp.Name(), &cfg, WithFlagSet(flag.NewFlagSet("tmp", flag.ContinueOnError)), WithEnv(env)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := testConfig{
+		Driver: &planTestDriver{DSN: "env"},
+		Backup: planTestDriver{DSN: "env"},
+	}
+	if diff := cmp.Diff(expected, cfg); diff != "" {
+		t.Errorf("(-want +got):\n%v", diff)
+	}
+
+	cfg = testConfig{Driver: &planTestDriver{}}
+	err := Load(tmp.Name(), &cfg, WithFlagSet(flag.NewFlagSet("tmp", flag.ContinueOnError)), WithEnv(nil), WithValidation())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	cfg = testConfig{Backup: &planTestDriver{}}
+	err = Load(tmp.Name(), &cfg, WithFlagSet(flag.NewFlagSet("tmp", flag.ContinueOnError)), WithEnv(nil), WithValidation())
+	if err == nil || !strings.Contains(err.Error(), "'backup.dsn'") {
+		t.Errorf("got: %v, expected error about backup.dsn", err)
+	}
+}
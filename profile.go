@@ -0,0 +1,104 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+// defaultProfileTable is the table of the profile overrides when WithProfile is given no table name.
+const defaultProfileTable string = "profile"
+
+// profileNames are the names that select the active profile and hold its overrides, set by WithProfile.
+type profileNames struct {
+	env   string
+	flag  string
+	table string
+}
+
+// WithProfile enables profiles: the overrides of the active profile are merged over the config before binding "env"
+// and "flag" values. The profile is selected by the environment variable env or the flag, with the same precedence as
+// other values, and its overrides are read from the [<table>.<name>] table of the file, and from the file with the
+// profile name before its extension (e.g. config.prod.toml for config.toml) if it exists. Empty env or flag names
+// don't select, and an empty table name is "profile".
+//
+// Without WithProfile, files are loaded as they are, and "profile" is an ordinary key.
+func WithProfile(env, flag, table string) Option {
+	return func(o *options) {
+		if table == "" {
+			table = defaultProfileTable
+		}
+		o.profile = &profileNames{env: env, flag: flag, table: table}
+	}
+}
+
+// activeProfile returns the profile selected by the flag or the environment variable of src.profile, with the same
+// precedence as other flags: flag given and env in the order of src.precedence, then flag default.
+func activeProfile(src *sources) string {
+	p := src.profile
+	if _, profile, ok := lookupSources(src, p.flag, p.env, nil, ""); ok {
+		return profile
+	}
+
+	if p.flag == "" {
+		return ""
+	}
+
+	if fl := src.flags.Lookup(p.flag); fl != nil {
+		return fl.DefValue
+	}
+
+	return ""
+}
+
+// applyProfileTable merges the overrides of profile from the [<table>.<name>] table of tree over tree. The profile
+// table is removed from tree even if no profile is active.
+func applyProfileTable(tree *toml.Tree, table string, profile string) error {
+	profiles, _ := tree.Get(table).(*toml.Tree)
+	if err := tree.Delete(table); err != nil {
+		return err
+	}
+
+	if profile == "" || profiles == nil {
+		return nil
+	}
+
+	if overrides, ok := profiles.Get(profile).(*toml.Tree); ok {
+		mergeTrees(tree, overrides)
+	}
+
+	return nil
+}
+
+// applyProfileFile merges the overrides of profile from the <name>.<ext> file next to filepath over tree, e.g.
+// config.prod.toml for config.toml, if it exists.
+func applyProfileFile(tree *toml.Tree, filepath string, profile string, src *sources) error {
+	if profile == "" {
+		return nil
+	}
+
+	profileFile := profileFilepath(filepath, profile)
+	if _, err := os.Stat(profileFile); os.IsNotExist(err) {
+		return nil
+	}
+
+	overrides, err := loadFileTree(profileFile, nil, src)
+	if err != nil {
+		return err
+	}
+
+	if err := overrides.Delete(src.profile.table); err != nil {
+		return err
+	}
+
+	mergeTrees(tree, overrides)
+	return nil
+}
+
+// profileFilepath returns the path of the profile file for path, e.g. config.prod.toml for config.toml.
+func profileFilepath(path string, profile string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + profile + ext
+}
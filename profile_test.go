@@ -0,0 +1,217 @@
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type profileTestConfig struct {
+	Name string `toml:"name"`
+	DB   struct {
+		Host string `toml:"host"`
+		Port int    `toml:"port"`
+	} `toml:"database"`
+}
+
+// profileTestOption enables profiles with the names of the README.
+var profileTestOption = WithProfile("APP_PROFILE", "profile", "")
+
+const profileTestFile = `
+name = "base"
+
+[database]
+host = "localhost"
+port = 5432
+
+[profile.prod]
+name = "prod"
+
+[profile.prod.database]
+host = "db.example.com"
+`
+
+func TestLoad_NoProfile(t *testing.T) {
+	os.Clearenv()
+	flag.CommandLine = flag.NewFlagSet("tmp", flag.ExitOnError)
+
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(profileTestFile); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	var cfg profileTestConfig
+	if err := Load(tmp.Name(), &cfg, profileTestOption); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if cfg.Name != "base" {
+		t.Errorf("got: %v, expected: %v", cfg.Name, "base")
+	}
+
+	if cfg.DB.Host != "localhost" {
+		t.Errorf("got: %v, expected: %v", cfg.DB.Host, "localhost")
+	}
+
+	tree, err := LoadTree(tmp.Name(), profileTestOption)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if tree.Has("profile") {
+		t.Errorf("expected profile table to be removed")
+	}
+}
+
+func TestLoad_ProfileFromEnv(t *testing.T) {
+	os.Clearenv()
+	flag.CommandLine = flag.NewFlagSet("tmp", flag.ExitOnError)
+
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(profileTestFile); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	os.Setenv("APP_PROFILE", "prod")
+
+	var cfg profileTestConfig
+	if err := Load(tmp.Name(), &cfg, profileTestOption); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if cfg.Name != "prod" {
+		t.Errorf("got: %v, expected: %v", cfg.Name, "prod")
+	}
+
+	if cfg.DB.Host != "db.example.com" {
+		t.Errorf("got: %v, expected: %v", cfg.DB.Host, "db.example.com")
+	}
+
+	// not overridden by the profile
+	if cfg.DB.Port != 5432 {
+		t.Errorf("got: %v, expected: %v", cfg.DB.Port, 5432)
+	}
+}
+
+func TestLoad_ProfileFileFromFlag(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("APP_PROFILE", "staging")
+
+	fs := flag.NewFlagSet("tmp", flag.ExitOnError)
+	_ = fs.String("profile", "", "")
+	flag.CommandLine = fs
+	flag.CommandLine.Parse([]string{"-profile", "prod"}) // flag given
+
+	dir, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(dir)
+
+	base := filepath.Join(dir, "config.toml")
+	if err := ioutil.WriteFile(base, []byte(profileTestFile), 0644); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.prod.toml"), []byte(`
+[database]
+port = 6432
+`), 0644); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	var cfg profileTestConfig
+	if err := Load(base, &cfg, profileTestOption); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// from the profile table
+	if cfg.DB.Host != "db.example.com" {
+		t.Errorf("got: %v, expected: %v", cfg.DB.Host, "db.example.com")
+	}
+
+	// from the profile file
+	if cfg.DB.Port != 6432 {
+		t.Errorf("got: %v, expected: %v", cfg.DB.Port, 6432)
+	}
+}
+
+func TestLoad_ProfileFollowsPrecedence(t *testing.T) {
+	fs := flag.NewFlagSet("tmp", flag.ContinueOnError)
+	_ = fs.String("profile", "", "")
+	if err := fs.Parse([]string{"-profile", "staging"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(profileTestFile); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	// env overrides the given flag
+	l := New(WithFlagSet(fs), WithEnv(map[string]string{"APP_PROFILE": "prod"}), WithPrecedence(SourceEnv, SourceFlag, SourceFile), profileTestOption)
+
+	var cfg profileTestConfig
+	if err := l.Load(tmp.Name(), &cfg); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if cfg.Name != "prod" {
+		t.Errorf("got: %v, expected: %v", cfg.Name, "prod")
+	}
+}
+
+func TestLoad_ProfilesNotEnabled(t *testing.T) {
+	type testConfig struct {
+		Name    string `toml:"name"`
+		Profile string `toml:"profile"`
+	}
+
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString("name = \"base\"\nprofile = \"cpu\"\n"); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	var cfg testConfig
+	if err := Load(tmp.Name(), &cfg, WithEnv(map[string]string{"APP_PROFILE": "prod"})); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if cfg.Profile != "cpu" {
+		t.Errorf("got: %v, expected: %v", cfg.Profile, "cpu")
+	}
+
+	tree, err := LoadTree(tmp.Name())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if !tree.Has("profile") {
+		t.Errorf("expected profile key to be kept")
+	}
+}
+
+func TestLoad_ProfileCustomNames(t *testing.T) {
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString("name = \"base\"\n\n[env.prod]\nname = \"prod\"\n"); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	var cfg profileTestConfig
+	if err := Load(tmp.Name(), &cfg, WithProfile("STAGE", "", "env"), WithEnv(map[string]string{"STAGE": "prod"})); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if cfg.Name != "prod" {
+		t.Errorf("got: %v, expected: %v", cfg.Name, "prod")
+	}
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+// GenerateSample writes a sample TOML file for cfg to w. Every setting is written commented out with its default
+// value, and documented with its "description" tag, type, environment variable and flag.
+//
+// The default value of a setting is the flag default if it has a "flag" tag, the "default" tag if it has one, and the
+// current value in cfg otherwise.
+func GenerateSample(w io.Writer, cfg interface{}) error {
+	v, err := structValue(cfg)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := writeSampleTable(&buf, v, nil); err != nil {
+		return err
+	}
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// writeSampleTable writes the settings of v, followed by its nested tables.
+func writeSampleTable(buf *bytes.Buffer, v reflect.Value, path []string) error {
+	var tables []fileField
+	for _, f := range fileFields(v) {
+		if isTableType(f.field.Type) {
+			tables = append(tables, f)
+			continue
+		}
+
+		if err := writeSampleKey(buf, f); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range tables {
+		tablePath := append(append([]string{}, path...), f.key)
+
+		elem := derefValue(f.value)
+		if elem.Kind() == reflect.Struct {
+			writeSampleHeader(buf, f, "# ["+keyPath(tablePath)+"]")
+			if err := writeSampleTable(buf, elem, tablePath); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Array of tables: write a single zero element as an example if there is none.
+		elems := []reflect.Value{reflect.New(elem.Type().Elem()).Elem()}
+		if elem.Len() > 0 {
+			elems = elems[:0]
+			for i := 0; i < elem.Len(); i++ {
+				elems = append(elems, elem.Index(i))
+			}
+		}
+
+		for _, e := range elems {
+			writeSampleHeader(buf, f, "# [["+keyPath(tablePath)+"]]")
+			if err := writeSampleTable(buf, derefValue(e), tablePath); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// writeSampleHeader writes a table header with the description of f.
+func writeSampleHeader(buf *bytes.Buffer, f fileField, header string) {
+	if buf.Len() > 0 {
+		buf.WriteString("\n")
+	}
+
+	writeSampleDescription(buf, f.field)
+	buf.WriteString(header + "\n")
+}
+
+// writeSampleKey writes a commented out setting with its documentation.
+func writeSampleKey(buf *bytes.Buffer, f fileField) error {
+	val, err := defaultValue(f.field, f.value)
+	if err != nil {
+		return fmt.Errorf("%v: %v", f.field.Name, err)
+	}
+
+	repr, err := toml.ValueStringRepresentation(val, "", "", toml.OrderAlphabetical, false)
+	if err != nil {
+		return fmt.Errorf("%v: %v", f.field.Name, err)
+	}
+
+	if buf.Len() > 0 {
+		buf.WriteString("\n")
+	}
+
+	writeSampleDescription(buf, f.field)
+
+	info := []string{"type: " + f.field.Type.String()}
+	if env := f.field.Tag.Get(envTag); env != "" && env != "-" {
+		info = append(info, "env: "+env)
+	}
+	if fl := f.field.Tag.Get(flagTag); fl != "" && fl != "-" {
+		info = append(info, "flag: -"+fl)
+	}
+	buf.WriteString("## " + strings.Join(info, ", ") + "\n")
+
+	buf.WriteString("# " + keyPath([]string{f.key}) + " = " + repr + "\n")
+	return nil
+}
+
+// writeSampleDescription writes the "description" tag of field as a comment.
+func writeSampleDescription(buf *bytes.Buffer, field reflect.StructField) {
+	desc := field.Tag.Get(descriptionTag)
+	if desc == "" {
+		return
+	}
+
+	for _, line := range strings.Split(desc, "\n") {
+		buf.WriteString(strings.TrimRight("## "+line, " ") + "\n")
+	}
+}
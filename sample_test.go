@@ -0,0 +1,152 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type sampleTestConfig struct {
+	Name     string        `toml:"name" env:"NAME" description:"Name of the service."`
+	Port     int           `toml:"port" flag:"port" description:"Port to listen on."`
+	Interval time.Duration `toml:"interval" default:"5s"`
+	Tags     []string      `toml:"tags"`
+	Secret   string        `toml:"-" env:"SECRET"`
+	DB       *struct {
+		Host string `toml:"host" description:"Database host."`
+	} `toml:"database" description:"Database settings."`
+	Servers []struct {
+		Host string `toml:"host"`
+	} `toml:"servers"`
+}
+
+func TestGenerateSample(t *testing.T) {
+	fs := flag.NewFlagSet("tmp", flag.ExitOnError)
+	_ = fs.Int("port", 8080, "")
+	flag.CommandLine = fs
+
+	cfg := sampleTestConfig{
+		Name: "example",
+		Tags: []string{"a", "b"},
+	}
+
+	var buf bytes.Buffer
+	if err := GenerateSample(&buf, &cfg); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := `## Name of the service.
+## type: string, env: NAME
+# name = "example"
+
+## Port to listen on.
+## type: int, flag: -port
+# port = 8080
+
+## type: time.Duration
+# interval = "5s"
+
+## type: []string
+# tags = ["a", "b"]
+
+## Database settings.
+# [database]
+
+## Database host.
+## type: string
+# host = ""
+
+# [[servers]]
+
+## type: string
+# host = ""
+`
+
+	if diff := cmp.Diff(expected, buf.String()); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%v", diff)
+	}
+}
+
+func TestGenerateSample_Uncommented(t *testing.T) {
+	os.Clearenv()
+
+	fs := flag.NewFlagSet("tmp", flag.ExitOnError)
+	_ = fs.Int("port", 8080, "")
+	flag.CommandLine = fs
+
+	cfg := sampleTestConfig{
+		Name: "example",
+		Tags: []string{"a", "b"},
+	}
+
+	var buf bytes.Buffer
+	if err := GenerateSample(&buf, &cfg); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// Uncomment all settings, leaving the documentation as is.
+	var lines []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "# ") {
+			line = strings.TrimPrefix(line, "# ")
+		}
+		lines = append(lines, line)
+	}
+
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(strings.Join(lines, "\n")); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	var got sampleTestConfig
+	if err := Load(tmp.Name(), &got); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if got.Name != "example" {
+		t.Errorf("got: %v, expected: %v", got.Name, "example")
+	}
+
+	if got.Port != 8080 {
+		t.Errorf("got: %v, expected: %v", got.Port, 8080)
+	}
+
+	if got.Interval != 5*time.Second {
+		t.Errorf("got: %v, expected: %v", got.Interval, 5*time.Second)
+	}
+
+	if len(got.Servers) != 1 {
+		t.Errorf("got: %v, expected: %v", len(got.Servers), 1)
+	}
+}
+
+func TestGenerateSample_InvalidDefault(t *testing.T) {
+	cfg := struct {
+		Workers int `toml:"workers" default:"many"`
+	}{}
+
+	err := GenerateSample(ioutil.Discard, &cfg)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	if !strings.HasPrefix(err.Error(), `Workers: invalid default "many": `) {
+		t.Errorf("got: %v, expected an invalid default error for Workers", err)
+	}
+
+	if _, schemaErr := JSONSchema(&cfg); schemaErr == nil || schemaErr.Error() != err.Error() {
+		t.Errorf("got: %v, expected: %v", schemaErr, err)
+	}
+
+	if docsErr := GenerateMarkdown(ioutil.Discard, &cfg); docsErr == nil || docsErr.Error() != err.Error() {
+		t.Errorf("got: %v, expected: %v", docsErr, err)
+	}
+}
@@ -0,0 +1,266 @@
+package config
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// jsonSchemaDraft is the JSON Schema version JSONSchema produces.
+const jsonSchemaDraft string = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema returns a JSON Schema (draft 2020-12) describing the config file of cfg, which can be used by editors to
+// autocomplete and validate it. Keys are named after the "toml" struct tags, and the "description", "enum", "min",
+// "max" and "required" tags are included in the schema.
+//
+// Since required settings can also be set by environment variables and flags, they are only marked as required in the
+// schema if they have no "env" or "flag" tags. The old names of the "aliases" tag are included as deprecated keys, as
+// they are still read from the file.
+func JSONSchema(cfg interface{}) ([]byte, error) {
+	v, err := structValue(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	schema, err := structSchema(v, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	schema["$schema"] = jsonSchemaDraft
+
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// aliasSchema is the schema of an old name of a field, at its path from the table the aliases are resolved from.
+type aliasSchema struct {
+	path   []string
+	schema map[string]interface{}
+}
+
+// structSchema returns the schema of a table, with the fields of v as its properties. aliases collects the schemas of
+// the old names of the fields of a nested table, which are added to the table they are resolved from once it is
+// complete. It is nil if v is that table, i.e. the root of the file or an element of an array or map of tables.
+func structSchema(v reflect.Value, aliases *[]aliasSchema) (map[string]interface{}, error) {
+	properties := make(map[string]interface{})
+	var required []string
+
+	root := aliases == nil
+	if root {
+		aliases = &[]aliasSchema{}
+	}
+
+	for _, f := range fileFields(v) {
+		schema, err := fieldSchema(f, aliases)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", f.field.Name, err)
+		}
+		properties[f.key] = schema
+
+		var fp fieldPlan
+		fp.compileAliases(f.field.Tag.Get(aliasesTag))
+		for _, alias := range fp.aliases {
+			deprecated := map[string]interface{}{"deprecated": true}
+			for keyword, value := range schema {
+				deprecated[keyword] = value
+			}
+			*aliases = append(*aliases, aliasSchema{path: strings.Split(alias, "."), schema: deprecated})
+		}
+
+		if !isRequired(f.field.Tag.Get(requiredTag)) {
+			continue
+		}
+
+		env, fl := f.field.Tag.Get(envTag), f.field.Tag.Get(flagTag)
+		if (env == "" || env == "-") && (fl == "" || fl == "-") {
+			required = append(required, f.key)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	if root {
+		for _, alias := range *aliases {
+			addAliasSchema(schema, alias.path, alias.schema)
+		}
+	}
+
+	return schema, nil
+}
+
+// addAliasSchema adds the schema of an alias at path to the table schema, unless a field has the same key. Tables on
+// the path that no field has anymore are added too.
+func addAliasSchema(schema map[string]interface{}, path []string, alias map[string]interface{}) {
+	properties, ok := schema["properties"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	key := path[0]
+	if len(path) == 1 {
+		if _, ok := properties[key]; !ok {
+			properties[key] = alias
+		}
+		return
+	}
+
+	table, ok := properties[key].(map[string]interface{})
+	if !ok {
+		table = map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": false,
+			"properties":           map[string]interface{}{},
+			"deprecated":           true,
+		}
+		properties[key] = table
+	}
+
+	addAliasSchema(table, path[1:], alias)
+}
+
+// fieldSchema returns the schema of f, including its description, default value, allowed values and bounds. aliases
+// collects the old names of the fields of nested tables, as in structSchema.
+func fieldSchema(f fileField, aliases *[]aliasSchema) (map[string]interface{}, error) {
+	schema, err := typeSchema(f.field.Type, f.value, aliases)
+	if err != nil {
+		return nil, err
+	}
+
+	if desc := f.field.Tag.Get(descriptionTag); desc != "" {
+		schema["description"] = desc
+	}
+
+	if isTableType(f.field.Type) {
+		return schema, nil
+	}
+
+	def, err := rawDefaultValue(f.field, f.value)
+	if err != nil {
+		return nil, err
+	}
+	schema["default"] = def
+
+	if tag := f.field.Tag.Get(enumTag); tag != "" {
+		// The allowed values of a slice apply to its items.
+		enumSchema, enumType := schema, derefType(f.field.Type)
+		if enumType.Kind() == reflect.Slice || enumType.Kind() == reflect.Array {
+			enumSchema, enumType = schema["items"].(map[string]interface{}), derefType(enumType.Elem())
+		}
+
+		var values []interface{}
+		for _, s := range strings.Split(tag, ",") {
+			v := reflect.New(enumType).Elem()
+			if err := setDefaultValue(v, f.field.Name, strings.TrimSpace(s)); err != nil {
+				return nil, err
+			}
+
+			val, _, err := marshalValue(v, marshalOptions{})
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, val)
+		}
+		enumSchema["enum"] = values
+	}
+
+	for keyword, tag := range map[string]string{"minimum": minTag, "maximum": maxTag} {
+		bound := f.field.Tag.Get(tag)
+		if bound == "" {
+			continue
+		}
+
+		val, err := boundValue(derefType(f.field.Type), bound)
+		if err != nil {
+			return nil, err
+		}
+		schema[keyword] = val
+	}
+
+	return schema, nil
+}
+
+// boundValue returns the "min" or "max" struct-tag bound as a number of type t, the way an integer or float of the
+// config file is read into t (e.g. bytes for a ByteSize).
+func boundValue(t reflect.Type, bound string) (interface{}, error) {
+	v := reflect.New(t).Elem()
+	if err := setDefaultValue(v, t.Name(), strings.TrimSpace(bound)); err != nil {
+		return nil, fmt.Errorf("invalid bound %q: %v", bound, err)
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	}
+
+	return nil, fmt.Errorf("%v can't have bounds, as it is not a number", t)
+}
+
+// typeSchema returns the schema of t, mapping Go kinds to JSON types the way setValue does. Durations, ByteSizes and
+// Percents have the formats "go-duration", "byte-size" and "percent", so that their strings can be compared to their
+// bounds, which are numbers. Tables are walked through
+// v, which can be a zero value. aliases collects the old names of the fields of a nested table, as in structSchema.
+// The elements of arrays and maps of tables resolve their own.
+func typeSchema(t reflect.Type, v reflect.Value, aliases *[]aliasSchema) (map[string]interface{}, error) {
+	t, v = derefType(t), derefValue(v)
+
+	switch t {
+	case reflect.TypeOf(time.Duration(0)):
+		return map[string]interface{}{"type": []string{"string", "integer"}, "format": "go-duration"}, nil
+	case reflect.TypeOf(time.Time{}):
+		return map[string]interface{}{"type": "string", "format": "date-time"}, nil
+	case reflect.TypeOf(ByteSize(0)):
+		return map[string]interface{}{"type": []string{"string", "integer"}, "format": "byte-size"}, nil
+	case reflect.TypeOf(Percent(0)):
+		return map[string]interface{}{"type": []string{"string", "number"}, "format": "percent"}, nil
+	}
+
+	if reflect.PtrTo(t).Implements(reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()) {
+		return map[string]interface{}{"type": "string"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return map[string]interface{}{"type": "integer", "minimum": 0}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}, nil
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Struct:
+		return structSchema(v, aliases)
+	case reflect.Slice, reflect.Array:
+		items, err := typeSchema(t.Elem(), reflect.New(t.Elem()).Elem(), nil)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	case reflect.Map:
+		values, err := typeSchema(t.Elem(), reflect.New(t.Elem()).Elem(), nil)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": values}, nil
+	case reflect.Interface:
+		return map[string]interface{}{}, nil
+	}
+
+	return nil, fmt.Errorf("unhandled type %v", t.Kind())
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestJSONSchema(t *testing.T) {
+	var cfg struct {
+		Name     string            `toml:"name" required:"true" description:"Name of the service."`
+		Level    string            `toml:"level" enum:"debug,info" default:"info"`
+		Port     uint16            `toml:"port" env:"PORT" required:"true"`
+		Interval time.Duration     `toml:"interval"`
+		Memory   ByteSize          `toml:"memory" min:"64MiB"`
+		Usage    Percent           `toml:"usage" max:"100%"`
+		Secret   string            `toml:"-" env:"SECRET"`
+		Labels   map[string]string `toml:"labels"`
+		DB       *struct {
+			Host string `toml:"host"`
+		} `toml:"database"`
+		Servers []struct {
+			Weight float64 `toml:"weight"`
+		} `toml:"servers"`
+	}
+
+	b, err := JSONSchema(&cfg)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "additionalProperties": false,
+  "required": ["name"],
+  "properties": {
+    "name": {"type": "string", "description": "Name of the service.", "default": ""},
+    "level": {"type": "string", "enum": ["debug", "info"], "default": "info"},
+    "port": {"type": "integer", "minimum": 0, "default": 0},
+    "interval": {"type": ["string", "integer"], "format": "go-duration", "default": "0s"},
+    "memory": {"type": ["string", "integer"], "format": "byte-size", "minimum": 67108864, "default": "0B"},
+    "usage": {"type": ["string", "number"], "format": "percent", "maximum": 100, "default": "0%"},
+    "labels": {"type": "object", "additionalProperties": {"type": "string"}, "default": {}},
+    "database": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "host": {"type": "string", "default": ""}
+      }
+    },
+    "servers": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "additionalProperties": false,
+        "properties": {
+          "weight": {"type": "number", "default": 0}
+        }
+      }
+    }
+  }
+}`
+
+	var got, want interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := json.Unmarshal([]byte(expected), &want); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%v", diff)
+	}
+}
+
+func TestJSONSchema_Aliases(t *testing.T) {
+	var cfg struct {
+		Pool struct {
+			MaxConnections int `toml:"max_connections" aliases:"max_conns,pool.maxconns,env=APP_MAX_CONNS"`
+		} `toml:"pool"`
+		Servers []struct {
+			Addr string `toml:"addr" aliases:"address,old.addr"`
+		} `toml:"servers"`
+	}
+
+	b, err := JSONSchema(&cfg)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "additionalProperties": false,
+  "properties": {
+    "max_conns": {"type": "integer", "default": 0, "deprecated": true},
+    "pool": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "max_connections": {"type": "integer", "default": 0},
+        "maxconns": {"type": "integer", "default": 0, "deprecated": true}
+      }
+    },
+    "servers": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "additionalProperties": false,
+        "properties": {
+          "addr": {"type": "string", "default": ""},
+          "address": {"type": "string", "default": "", "deprecated": true},
+          "old": {
+            "type": "object",
+            "additionalProperties": false,
+            "deprecated": true,
+            "properties": {
+              "addr": {"type": "string", "default": "", "deprecated": true}
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+	var got, want interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := json.Unmarshal([]byte(expected), &want); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%v", diff)
+	}
+}
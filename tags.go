@@ -0,0 +1,278 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+// tagNames are the names of the struct-tags that bind fields to the file, the environment and the flags.
+type tagNames struct {
+	toml string
+	env  string
+	flag string
+
+	// combined is the name of a single struct-tag that replaces the others, e.g. `config:"toml=host,env=HOST,flag=host"`.
+	combined string
+}
+
+// defaultTagNames are the struct-tags used without WithTagNames or WithCombinedTag.
+var defaultTagNames = tagNames{toml: tomlTag, env: envTag, flag: flagTag}
+
+// WithTagNames renames the "toml", "env" and "flag" struct-tags, so they don't conflict with the tags of other
+// libraries. Empty names keep the default.
+func WithTagNames(toml, env, flag string) Option {
+	return func(o *options) {
+		o.tags.combined = ""
+		if toml != "" {
+			o.tags.toml = toml
+		}
+		if env != "" {
+			o.tags.env = env
+		}
+		if flag != "" {
+			o.tags.flag = flag
+		}
+	}
+}
+
+// WithCombinedTag reads the toml key, the environment variable and the flag of fields from the single struct-tag name,
+// instead of the "toml", "env" and "flag" struct-tags. Its value is a comma separated list of source=name pairs:
+//
+//	Host string `config:"toml=host,env=DB_HOST,flag=db-host"`
+//
+// A value of "-" ignores the field.
+func WithCombinedTag(name string) Option {
+	return func(o *options) {
+		o.tags.combined = name
+	}
+}
+
+// get returns the value of the struct-tag for source, which is one of "toml", "env" and "flag". tag is the Get method of
+// the field's struct-tags.
+func (t tagNames) get(tag func(string) string, source string) string {
+	if t.combined == "" {
+		switch source {
+		case tomlTag:
+			return tag(t.toml)
+		case envTag:
+			return tag(t.env)
+		case flagTag:
+			return tag(t.flag)
+		}
+		return ""
+	}
+
+	combined := tag(t.combined)
+	if strings.TrimSpace(combined) == "-" {
+		return "-"
+	}
+
+	for _, pair := range strings.Split(combined, ",") {
+		i := strings.Index(pair, "=")
+		if i < 0 {
+			continue
+		}
+
+		if strings.TrimSpace(pair[:i]) == source {
+			return strings.TrimSpace(pair[i+1:])
+		}
+	}
+
+	return ""
+}
+
+// tomlName returns the toml key of field, without options such as "omitempty". ok is false if the field is ignored.
+func (t tagNames) tomlName(tag func(string) string) (name string, ok bool) {
+	name = strings.TrimSpace(strings.Split(t.get(tag, tomlTag), ",")[0])
+	return name, name != "-"
+}
+
+// isDefault will check if t are the default struct-tags, which go-toml understands
+func (t tagNames) isDefault() bool {
+	return t == defaultTagNames
+}
+
+// decoderTag returns the struct-tag go-toml reads keys from when decoding with t.
+func (t tagNames) decoderTag() string {
+	if t.combined != "" {
+		return t.combined
+	}
+	return t.toml
+}
+
+// renameKeys renames the keys of tree that bind to the fields of typ from the names in t to the names go-toml looks for
+// with the decoder tag, recursing into tables that bind to structs.
+func (t tagNames) renameKeys(tree *toml.Tree, typ reflect.Type) {
+	typ = derefType(typ)
+	if !isStructType(typ) {
+		return
+	}
+
+	renamed := make(map[string]interface{})
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		name, ok := t.tomlName(sf.Tag.Get)
+		if !ok {
+			continue
+		}
+
+		key, found := lookupKey(tree, name, sf.Name)
+		if !found {
+			if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+				// go-toml decodes embedded structs from the same table
+				t.renameKeys(tree, sf.Type)
+			}
+			continue
+		}
+
+		val := tree.GetPath([]string{key})
+		t.renameValueKeys(val, sf.Type)
+
+		_ = tree.DeletePath([]string{key})
+		renamed[decoderKey(sf, t.decoderTag())] = val
+	}
+
+	for key, val := range renamed {
+		tree.SetPath([]string{key}, val)
+	}
+}
+
+// renameValueKeys renames the keys of the tables in val, which binds to a value of type typ.
+func (t tagNames) renameValueKeys(val interface{}, typ reflect.Type) {
+	typ = derefType(typ)
+	switch v := val.(type) {
+	case *toml.Tree:
+		if typ.Kind() == reflect.Map {
+			for _, key := range v.Keys() {
+				t.renameValueKeys(v.GetPath([]string{key}), typ.Elem())
+			}
+			return
+		}
+
+		t.renameKeys(v, typ)
+	case []*toml.Tree:
+		if typ.Kind() != reflect.Slice && typ.Kind() != reflect.Array {
+			return
+		}
+
+		for _, elem := range v {
+			t.renameKeys(elem, typ.Elem())
+		}
+	}
+}
+
+// originalPath returns the path in tree of the key at path in the tree renamed by renameKeys, with the keys that bind
+// to the fields of typ renamed back to their names in tree. The elements of arrays of tables are in path as "[i]".
+func (t tagNames) originalPath(tree *toml.Tree, typ reflect.Type, path []string) []string {
+	orig := make([]string, 0, len(path))
+
+	var val interface{} = tree
+	for _, key := range path {
+		if typ != nil {
+			typ = derefType(typ)
+		}
+
+		switch v := val.(type) {
+		case *toml.Tree:
+			switch {
+			case typ != nil && isStructType(typ):
+				var ok bool
+				if key, typ, ok = t.originalKey(v, typ, key); !ok {
+					typ = nil
+				}
+			case typ != nil && typ.Kind() == reflect.Map:
+				typ = typ.Elem()
+			default:
+				typ = nil
+			}
+			val = v.GetPath([]string{key})
+		case []*toml.Tree:
+			var i int
+			if _, err := fmt.Sscanf(key, "[%d]", &i); err != nil || i < 0 || i >= len(v) {
+				return append(orig, path[len(orig):]...)
+			}
+
+			val = v[i]
+			if typ != nil && (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array) {
+				typ = typ.Elem()
+			} else {
+				typ = nil
+			}
+		default:
+			return append(orig, path[len(orig):]...)
+		}
+
+		orig = append(orig, key)
+	}
+
+	return orig
+}
+
+// originalKey returns the key of tree that renameKeys renamed to key, and the type of the field it binds to. key is
+// returned as is if it wasn't renamed.
+func (t tagNames) originalKey(tree *toml.Tree, typ reflect.Type, key string) (string, reflect.Type, bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		name, ok := t.tomlName(sf.Tag.Get)
+		if !ok {
+			continue
+		}
+
+		orig, found := lookupKey(tree, name, sf.Name)
+		if !found {
+			if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+				if orig, ft, ok := t.originalKey(tree, sf.Type, key); ok {
+					return orig, ft, true
+				}
+			}
+			continue
+		}
+
+		if decoderKey(sf, t.decoderTag()) == key {
+			return orig, sf.Type, true
+		}
+	}
+
+	return key, nil, false
+}
+
+// lookupKey returns the key of tree that binds to a field with the toml name, or fieldName if it has none, trying the
+// same variants of the name as go-toml.
+func lookupKey(tree *toml.Tree, name, fieldName string) (string, bool) {
+	if name == "" {
+		name = fieldName
+	}
+
+	keys := []string{
+		name,
+		strings.ToLower(name),
+		strings.ToTitle(name),
+		strings.ToLower(name[:1]) + name[1:],
+	}
+	for _, key := range keys {
+		if tree.HasPath([]string{key}) {
+			return key, true
+		}
+	}
+
+	return "", false
+}
+
+// decoderKey returns the key go-toml looks for to decode sf, reading its name from the struct-tag tag.
+func decoderKey(sf reflect.StructField, tag string) string {
+	if name := strings.TrimSpace(strings.Split(sf.Tag.Get(tag), ",")[0]); name != "" {
+		return name
+	}
+	return sf.Name
+}
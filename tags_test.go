@@ -0,0 +1,114 @@
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoad_WithTagNames(t *testing.T) {
+	type dbConfig struct {
+		Host string `cfg:"host" environ:"DB_HOST"`
+		Port int    `cfg:"port" cli:"db-port"`
+	}
+
+	// The "env" and "toml" tags belong to another library
+	type testConfig struct {
+		Name string    `cfg:"name" toml:"other_name" env:"OTHER_NAME"`
+		DB   *dbConfig `cfg:"database"`
+	}
+
+	fs := flag.NewFlagSet("tmp", flag.ContinueOnError)
+	_ = fs.Int("db-port", 5432, "")
+	if err := fs.Parse([]string{"-db-port", "6432"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString("name = \"example\"\nother_name = \"other\"\n\n[database]\nhost = \"localhost\"\n"); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	env := map[string]string{"DB_HOST": "db.example.com", "OTHER_NAME": "other"}
+	l := New(WithTagNames("cfg", "environ", "cli"), WithFlagSet(fs), WithEnv(env))
+
+	var cfg testConfig
+	if err := l.Load(tmp.Name(), &cfg); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := testConfig{
+		Name: "example",
+		DB:   &dbConfig{Host: "db.example.com", Port: 6432},
+	}
+	if diff := cmp.Diff(expected, cfg); diff != "" {
+		t.Errorf("(-want +got):\n%v", diff)
+	}
+}
+
+func TestLoad_WithCombinedTag(t *testing.T) {
+	type server struct {
+		Addr string `config:"toml=address"`
+	}
+
+	type Common struct {
+		Region string `config:"toml=region,env=REGION"`
+	}
+
+	type testConfig struct {
+		Common
+		Name    string            `config:"toml=name" toml:"other_name"`
+		Level   string            `config:"toml=log_level,env=LOG_LEVEL,flag=log-level" enum:"debug,info"`
+		Host    string            `config:"env=HOST"`
+		Servers []server          `config:"toml=servers"`
+		Peers   map[string]server `config:"toml=peers"`
+		Ignored string            `config:"-"`
+	}
+
+	fs := flag.NewFlagSet("tmp", flag.ContinueOnError)
+	_ = fs.String("log-level", "info", "")
+	if err := fs.Parse(nil); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(`
+name = "example"
+region = "eu-west-1"
+log_level = "debug"
+
+[[servers]]
+address = "10.0.0.1:80"
+
+[peers.a]
+address = "10.0.1.1:80"
+`); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	l := New(WithCombinedTag("config"), WithFlagSet(fs), WithEnv(map[string]string{"HOST": "example.com"}), WithStrict())
+
+	var cfg testConfig
+	if err := l.Load(tmp.Name(), &cfg); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := testConfig{
+		Common:  Common{Region: "eu-west-1"},
+		Name:    "example",
+		Level:   "debug",
+		Host:    "example.com",
+		Servers: []server{{Addr: "10.0.0.1:80"}},
+		Peers:   map[string]server{"a": {Addr: "10.0.1.1:80"}},
+	}
+	if diff := cmp.Diff(expected, cfg); diff != "" {
+		t.Errorf("(-want +got):\n%v", diff)
+	}
+}
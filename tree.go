@@ -0,0 +1,22 @@
+package config
+
+import (
+	"github.com/pelletier/go-toml"
+)
+
+// mergeTrees deep-merges src over dst. Tables are merged key by key, any other value in src replaces the one in dst.
+func mergeTrees(dst, src *toml.Tree) {
+	for _, key := range src.Keys() {
+		srcVal := src.Get(key)
+
+		if srcTree, ok := srcVal.(*toml.Tree); ok {
+			if dstTree, ok := dst.Get(key).(*toml.Tree); ok {
+				mergeTrees(dstTree, srcTree)
+				continue
+			}
+		}
+
+		dst.SetPath([]string{key}, srcVal)
+		dst.SetPositionPath([]string{key}, src.GetPosition(key))
+	}
+}
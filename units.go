@@ -0,0 +1,167 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ByteSize is a number of bytes, written with SI (kB, MB, GB, ...) or IEC (KiB, MiB, GiB, ...) suffixes, e.g. "512MiB"
+// or "2GB". Numbers without a suffix are bytes. It can be set by the file, environment variables and flags, and can be
+// used as a flag.Value.
+type ByteSize uint64
+
+// Byte sizes with SI and IEC suffixes.
+const (
+	Byte ByteSize = 1
+
+	KB ByteSize = 1000 * Byte
+	MB ByteSize = 1000 * KB
+	GB ByteSize = 1000 * MB
+	TB ByteSize = 1000 * GB
+	PB ByteSize = 1000 * TB
+	EB ByteSize = 1000 * PB
+
+	KiB ByteSize = 1 << 10
+	MiB ByteSize = 1 << 20
+	GiB ByteSize = 1 << 30
+	TiB ByteSize = 1 << 40
+	PiB ByteSize = 1 << 50
+	EiB ByteSize = 1 << 60
+)
+
+// byteUnits are the suffixes of ByteSize, from the largest unit to the smallest.
+var byteUnits = []struct {
+	suffix string
+	size   ByteSize
+}{
+	{"EiB", EiB}, {"EB", EB},
+	{"PiB", PiB}, {"PB", PB},
+	{"TiB", TiB}, {"TB", TB},
+	{"GiB", GiB}, {"GB", GB},
+	{"MiB", MiB}, {"MB", MB},
+	{"KiB", KiB}, {"kB", KB},
+	{"B", Byte},
+}
+
+// ParseByteSize parses s into a ByteSize. Suffixes are case-insensitive, and the number can have a fraction as long as
+// the size is a whole number of bytes, e.g. "1.5GiB".
+func ParseByteSize(s string) (ByteSize, error) {
+	trimmed := strings.TrimSpace(s)
+	i := strings.LastIndexFunc(trimmed, func(r rune) bool { return !unicode.IsLetter(r) }) + 1
+	num, unit := strings.TrimSpace(trimmed[:i]), trimmed[i:]
+
+	size := Byte
+	if unit != "" {
+		found := false
+		for _, u := range byteUnits {
+			if strings.EqualFold(unit, u.suffix) {
+				size, found = u.size, true
+				break
+			}
+		}
+
+		if !found {
+			return 0, fmt.Errorf("invalid byte size %q: unknown unit %q", s, unit)
+		}
+	}
+
+	r, ok := new(big.Rat).SetString(num)
+	if !ok || strings.Contains(num, "/") {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+
+	r.Mul(r, new(big.Rat).SetInt(new(big.Int).SetUint64(uint64(size))))
+	if r.Sign() < 0 || !r.IsInt() {
+		return 0, fmt.Errorf("invalid byte size %q: not a whole number of bytes", s)
+	}
+
+	if !r.Num().IsUint64() {
+		return 0, fmt.Errorf("invalid byte size %q: out of range", s)
+	}
+
+	return ByteSize(r.Num().Uint64()), nil
+}
+
+// String returns b with the unit that writes it as the smallest whole number, e.g. "512MiB" or "2GB".
+func (b ByteSize) String() string {
+	best := byteUnits[len(byteUnits)-1]
+	for _, u := range byteUnits {
+		if b%u.size == 0 && b/u.size < b/best.size {
+			best = u
+		}
+	}
+
+	return strconv.FormatUint(uint64(b/best.size), 10) + best.suffix
+}
+
+// Set implements flag.Value.
+func (b *ByteSize) Set(s string) error {
+	size, err := ParseByteSize(s)
+	if err != nil {
+		return err
+	}
+
+	*b = size
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (b ByteSize) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	return b.Set(string(text))
+}
+
+// Percent is a percentage, written with or without a "%" suffix, e.g. "75%" or 75. It can be set by the file,
+// environment variables and flags, and can be used as a flag.Value.
+type Percent float64
+
+// ParsePercent parses s into a Percent. "75%" and "75" are both 75 percent.
+func ParsePercent(s string) (Percent, error) {
+	num := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "%"))
+
+	p, err := strconv.ParseFloat(num, 64)
+	if err != nil || math.IsNaN(p) || math.IsInf(p, 0) {
+		return 0, fmt.Errorf("invalid percentage %q", s)
+	}
+
+	return Percent(p), nil
+}
+
+// Fraction returns p as a fraction of one, e.g. 0.75 for 75%.
+func (p Percent) Fraction() float64 {
+	return float64(p) / 100
+}
+
+// String returns p with a "%" suffix, e.g. "75%".
+func (p Percent) String() string {
+	return strconv.FormatFloat(float64(p), 'f', -1, 64) + "%"
+}
+
+// Set implements flag.Value.
+func (p *Percent) Set(s string) error {
+	percent, err := ParsePercent(s)
+	if err != nil {
+		return err
+	}
+
+	*p = percent
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (p Percent) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (p *Percent) UnmarshalText(text []byte) error {
+	return p.Set(string(text))
+}
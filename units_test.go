@@ -0,0 +1,207 @@
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected ByteSize
+		str      string
+	}{
+		{in: "0", expected: 0, str: "0B"},
+		{in: "1024", expected: KiB, str: "1KiB"},
+		{in: "512MiB", expected: 512 * MiB, str: "512MiB"},
+		{in: "512 mib", expected: 512 * MiB, str: "512MiB"},
+		{in: "2GB", expected: 2 * GB, str: "2GB"},
+		{in: "1.5GiB", expected: 1536 * MiB, str: "1536MiB"},
+		{in: "1.5kB", expected: 1500, str: "1500B"},
+		{in: " 3 TB ", expected: 3 * TB, str: "3TB"},
+		{in: "1000KiB", expected: 1000 * KiB, str: "1000KiB"},
+		{in: "16EiB", expected: 0, str: ""},
+		{in: "1.5", expected: 0, str: ""},
+		{in: "-1KB", expected: 0, str: ""},
+		{in: "1/2GB", expected: 0, str: ""},
+		{in: "12 parsecs", expected: 0, str: ""},
+		{in: "GB", expected: 0, str: ""},
+	}
+
+	for _, tc := range tests {
+		got, err := ParseByteSize(tc.in)
+		if tc.str == "" {
+			if err == nil {
+				t.Errorf("%q: expected error, got %v", tc.in, got)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tc.in, err)
+			continue
+		}
+
+		if got != tc.expected || got.String() != tc.str {
+			t.Errorf("%q: got: %v (%d), expected: %v (%d)", tc.in, got, uint64(got), tc.str, uint64(tc.expected))
+		}
+	}
+}
+
+func TestParsePercent(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected Percent
+		str      string
+	}{
+		{in: "75%", expected: 75, str: "75%"},
+		{in: "75", expected: 75, str: "75%"},
+		{in: " 12.5 % ", expected: 12.5, str: "12.5%"},
+		{in: "-5%", expected: -5, str: "-5%"},
+		{in: "NaN%", str: ""},
+		{in: "%", str: ""},
+		{in: "half", str: ""},
+	}
+
+	for _, tc := range tests {
+		got, err := ParsePercent(tc.in)
+		if tc.str == "" {
+			if err == nil {
+				t.Errorf("%q: expected error, got %v", tc.in, got)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tc.in, err)
+			continue
+		}
+
+		if got != tc.expected || got.String() != tc.str {
+			t.Errorf("%q: got: %v, expected: %v", tc.in, got, tc.str)
+		}
+	}
+
+	if got := Percent(75).Fraction(); got != 0.75 {
+		t.Errorf("got: %v, expected: %v", got, 0.75)
+	}
+}
+
+type unitsTestConfig struct {
+	Memory    ByteSize  `toml:"memory" min:"64MiB" max:"4GiB"`
+	Cache     ByteSize  `toml:"cache" env:"CACHE_SIZE"`
+	Buffer    *ByteSize `toml:"buffer" flag:"buffer"`
+	Threshold Percent   `toml:"threshold" flag:"threshold" min:"10%" max:"100%"`
+	Ratio     Percent   `toml:"ratio"`
+	Workers   int       `toml:"workers" min:"1" max:"64"`
+}
+
+func TestLoad_Units(t *testing.T) {
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(`
+memory = "512MiB"
+cache = "1GB"
+threshold = "75%"
+ratio = 12.5
+workers = 8
+`); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	threshold := Percent(50)
+
+	fs := flag.NewFlagSet("tmp", flag.ContinueOnError)
+	fs.Var(&threshold, "threshold", "")
+	_ = fs.String("buffer", "4KiB", "")
+	if err := fs.Parse([]string{"-threshold", "90%", "-buffer", "64KiB"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	var cfg unitsTestConfig
+	env := map[string]string{"CACHE_SIZE": "2GiB"}
+	if err := Load(tmp.Name(), &cfg, WithFlagSet(fs), WithEnv(env)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	buffer := 64 * KiB
+	expected := unitsTestConfig{
+		Memory:    512 * MiB,
+		Cache:     2 * GiB,
+		Buffer:    &buffer,
+		Threshold: 90,
+		Ratio:     12.5,
+		Workers:   8,
+	}
+	if diff := cmp.Diff(expected, cfg); diff != "" {
+		t.Errorf("(-want +got):\n%v", diff)
+	}
+
+	b, err := Marshal(&cfg)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	for _, s := range []string{`memory = "512MiB"`, `cache = "2GiB"`, `threshold = "90%"`} {
+		if !strings.Contains(string(b), s) {
+			t.Errorf("expected %s in:\n%s", s, b)
+		}
+	}
+}
+
+func TestLoad_ErrorIfOutOfRange(t *testing.T) {
+	tests := []struct {
+		file     string
+		expected string
+	}{
+		{file: `memory = "32MiB"`, expected: "field 'memory': should be at least 64MiB, got '32MiB'"},
+		{file: `memory = "5GB"`, expected: "field 'memory': should be at most 4GiB, got '5GB'"},
+		{file: `threshold = "120%"`, expected: "field 'threshold' (file threshold): should be at most 100%, got '120%'"},
+		{file: `workers = 100`, expected: "field 'workers': should be at most 64, got '100'"},
+		{file: `workers = 0`, expected: "field 'workers': should be at least 1, got '0'"},
+		{file: `threshold = "0%"`, expected: "field 'threshold' (file threshold): should be at least 10%, got '0%'"},
+		{file: `memory = "lots"`, expected: "invalid byte size"},
+	}
+
+	for _, tc := range tests {
+		tmp, _ := ioutil.TempFile("", "")
+		defer os.Remove(tmp.Name())
+
+		if _, err := tmp.WriteString(tc.file); err != nil {
+			t.Fatalf("write config file failed: %v", err)
+		}
+
+		fs := flag.NewFlagSet("tmp", flag.ContinueOnError)
+		_ = fs.String("threshold", "50%", "")
+		_ = fs.String("buffer", "", "")
+
+		var cfg unitsTestConfig
+		err := Load(tmp.Name(), &cfg, WithFlagSet(fs), WithEnv(nil))
+		if err == nil || !strings.Contains(err.Error(), tc.expected) {
+			t.Errorf("%v: got: %v, expected: %v", tc.file, err, tc.expected)
+		}
+	}
+}
+
+func TestLoad_ErrorIfBoundOnNonNumber(t *testing.T) {
+	var cfg struct {
+		Name string `toml:"name" min:"1"`
+	}
+
+	tmp, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(`name = "example"`); err != nil {
+		t.Fatalf("write config file failed: %v", err)
+	}
+
+	if err := Load(tmp.Name(), &cfg, WithFlagSet(flag.NewFlagSet("tmp", flag.ContinueOnError)), WithEnv(nil)); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+}